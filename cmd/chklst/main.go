@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"chklst-go/internal/api/handlers"
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/api/router"
+	"chklst-go/internal/auth"
+	"chklst-go/internal/config"
+	"chklst-go/internal/database"
+	"chklst-go/internal/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ Invalid configuration: %v", err)
+	}
+
+	utils.InitLogger(utils.LogLevel(strings.ToUpper(cfg.LogLevel)))
+
+	// Database
+	if err := database.InitDatabase(cfg.DBPath); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := database.Migrate(cfg.AutoMigrate); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	database.SetProductionEnvironments(cfg.ProductionEnvironments)
+
+	if err := bootstrapAdmin(cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	// Authentication
+	authSecret := cfg.AuthSecret
+	if authSecret == "" {
+		authSecret, err = auth.RandomSecret(32)
+		if err != nil {
+			log.Fatalf("❌ Failed to generate auth secret: %v", err)
+		}
+		utils.AppLogger.Warn("AUTH_SECRET not set, using a random secret; sessions will not survive a restart", nil)
+	}
+	tokens := auth.NewTokenIssuer(authSecret, time.Duration(cfg.TokenTTLHours)*time.Hour)
+	handlers.InitAuthHandlers(tokens)
+
+	// Backups
+	keyring, err := utils.LoadKeyring(cfg.BackupKey, cfg.BackupKeyFile)
+	if err != nil {
+		log.Fatalf("❌ Failed to load backup keys: %v", err)
+	}
+	if keyring != nil {
+		utils.AppLogger.Info("Backup encryption enabled", map[string]interface{}{
+			"key_id": keyring.ActiveKeyID(),
+		})
+	}
+	retention := utils.RetentionPolicy{
+		Hourly:  cfg.RetainHourly,
+		Daily:   cfg.RetainDaily,
+		Weekly:  cfg.RetainWeekly,
+		Monthly: cfg.RetainMonthly,
+	}
+	targets, err := utils.ParseBackupTargets(cfg.BackupTargets)
+	if err != nil {
+		log.Fatalf("❌ Invalid backup target: %v", err)
+	}
+	backupManager := utils.NewBackupManager(cfg.BackupDir, utils.BackupOptions{
+		Keyring:   keyring,
+		Retention: retention,
+		Targets:   targets,
+	})
+	gate := middleware.NewRequestGate()
+	handlers.InitAdminHandlers(backupManager, cfg.DBPath, gate)
+
+	// Scheduled jobs
+	scheduler := utils.NewScheduler()
+	if err := backupManager.RegisterJobs(scheduler, cfg.BackupSchedule, cfg.PruneSchedule); err != nil {
+		log.Fatalf("❌ Invalid job schedule: %v", err)
+	}
+	handlers.InitJobHandlers(scheduler)
+	scheduler.Start()
+
+	// HTTP server
+	app := fiber.New(fiber.Config{
+		AppName:   "chklst-go",
+		BodyLimit: cfg.MaxUploadMB * 1024 * 1024,
+	})
+	router.Setup(app, cfg, tokens, gate)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- app.Listen(":"+cfg.Port, fiber.ListenConfig{
+			DisableStartupMessage: true,
+		})
+	}()
+
+	utils.AppLogger.Info("Server started", map[string]interface{}{
+		"port":    cfg.Port,
+		"db_path": cfg.DBPath,
+	})
+
+	// Wait for shutdown signal or server failure
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case sig := <-quit:
+		utils.AppLogger.Info("Shutdown signal received", map[string]interface{}{
+			"signal": sig.String(),
+		})
+	case err := <-serverErr:
+		if err != nil {
+			utils.AppLogger.Error("Server stopped unexpectedly", err, nil)
+		}
+	}
+
+	shutdown(app, scheduler, time.Duration(cfg.ShutdownTimeout)*time.Second)
+}
+
+// bootstrapAdmin creates the initial admin account when no users exist yet
+// and makes sure at least one user holds the admin role.
+// A random password is generated and logged once if none is configured.
+func bootstrapAdmin(username, password string) error {
+	var count int64
+	if err := database.DB.Model(&database.User{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count users: %w", err)
+	}
+
+	if count == 0 {
+		generated := password == ""
+		if generated {
+			secret, err := auth.RandomSecret(12)
+			if err != nil {
+				return fmt.Errorf("failed to generate admin password: %w", err)
+			}
+			password = secret
+		}
+
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		admin := database.User{
+			Username:     username,
+			DisplayName:  username,
+			PasswordHash: hash,
+		}
+		if err := database.DB.Create(&admin).Error; err != nil {
+			return fmt.Errorf("failed to create admin user: %w", err)
+		}
+
+		data := map[string]interface{}{"username": username}
+		if generated {
+			data["password"] = password
+		}
+		utils.AppLogger.Warn("Initial admin user created", data)
+	}
+
+	var admins int64
+	if err := database.DB.Model(&database.Permission{}).Where("role = ?", auth.RoleAdmin).Count(&admins).Error; err != nil {
+		return fmt.Errorf("failed to count admin grants: %w", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	var admin database.User
+	if err := database.DB.Where("username = ?", username).First(&admin).Error; err != nil {
+		utils.AppLogger.Warn("No admin grant exists and admin user not found", map[string]interface{}{
+			"username": username,
+		})
+		return nil
+	}
+
+	grant := database.Permission{UserID: admin.ID, Role: string(auth.RoleAdmin)}
+	if err := database.DB.Create(&grant).Error; err != nil {
+		return fmt.Errorf("failed to grant admin role: %w", err)
+	}
+	utils.AppLogger.Info("Admin role granted", map[string]interface{}{
+		"username": username,
+	})
+
+	return nil
+}
+
+// shutdown drains in-flight requests, stops scheduled jobs and closes the database
+func shutdown(app *fiber.App, scheduler *utils.Scheduler, timeout time.Duration) {
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
+		utils.AppLogger.Error("Failed to drain HTTP server", err, nil)
+	}
+
+	scheduler.Stop()
+
+	if err := database.CloseDatabase(); err != nil {
+		utils.AppLogger.Error("Failed to close database", err, nil)
+	}
+
+	utils.AppLogger.Info("Shutdown complete", nil)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"chklst-go/internal/config"
+	"chklst-go/internal/database"
+	"chklst-go/internal/utils"
+)
+
+const migrateUsage = `Usage: chklst migrate <command> [flags]
+
+Commands:
+  up      Apply pending migrations (-to N stops at version N)
+  down    Revert applied migrations (-steps N, default 1)
+  status  List migrations and whether they are applied
+
+Flags are the same as for the server (e.g. -db).`
+
+// runMigrate implements the "chklst migrate" subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	command, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	to := fs.Int("to", 0, "Target version for up (0 = latest)")
+	steps := fs.Int("steps", 1, "Number of migrations to revert for down")
+
+	cfg, err := config.Parse(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	utils.InitLogger(utils.WARN)
+
+	if err := database.InitDatabase(cfg.DBPath); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	defer database.CloseDatabase()
+
+	switch command {
+	case "up":
+		applied, err := database.MigrateUp(*to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+	case "down":
+		reverted, err := database.MigrateDown(*steps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+	case "status":
+		if _, err := database.CheckSchemaVersion(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+		states, err := database.MigrationStatus()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.AppliedAt != nil {
+				appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	return 0
+}
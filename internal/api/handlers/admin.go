@@ -1,27 +1,38 @@
 package handlers
 
 import (
-	"chklst-go/internal/utils"
+	"bytes"
+	"errors"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/database"
+	"chklst-go/internal/utils"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
-var backupManager *utils.BackupManager
+var (
+	backupManager *utils.BackupManager
+	dbPath        string
+	requestGate   *middleware.RequestGate
+)
 
 // InitAdminHandlers initializes the admin handlers
-func InitAdminHandlers(bm *utils.BackupManager) {
+func InitAdminHandlers(bm *utils.BackupManager, databasePath string, gate *middleware.RequestGate) {
 	backupManager = bm
+	dbPath = databasePath
+	requestGate = gate
 }
 
 // BackupDatabase creates a database backup
 func BackupDatabase(c fiber.Ctx) error {
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "./chklst.db"
-	}
-
-	backupPath, err := backupManager.BackupDatabase(dbPath)
+	result, err := backupManager.BackupDatabase()
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to backup database",
@@ -30,14 +41,20 @@ func BackupDatabase(c fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"message":     "Database backed up successfully",
-		"backup_path": backupPath,
+		"name":        filepath.Base(result.Path),
+		"backup_path": result.Path,
+		"manifest":    result.Manifest,
+		"uploads":     result.Uploads,
 	})
 }
 
-// RestoreDatabase restores database from backup
+// RestoreDatabase restores the database from a backup in the backup
+// directory, or from a backup held by a remote target when target is given
 func RestoreDatabase(c fiber.Ctx) error {
 	var req struct {
-		BackupPath string `json:"backup_path"`
+		Name       string `json:"name"`
+		BackupPath string `json:"backup_path"` // deprecated, must be inside the backup directory
+		Target     string `json:"target"`
 	}
 
 	if err := c.Bind().JSON(&req); err != nil {
@@ -46,17 +63,65 @@ func RestoreDatabase(c fiber.Ctx) error {
 		})
 	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "./chklst.db"
+	if req.Target != "" {
+		return restoreDatabase(c, fiber.Map{"target": req.Target, "name": req.Name}, func() error {
+			return backupManager.RestoreFromTarget(req.Target, req.Name, dbPath)
+		})
+	}
+
+	ref := req.Name
+	if ref == "" {
+		ref = req.BackupPath
+	}
+	backupPath, err := backupManager.ResolveBackup(ref)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Backup not found",
+		})
+	}
+
+	return restoreDatabase(c, fiber.Map{"name": filepath.Base(backupPath)}, func() error {
+		return backupManager.RestoreDatabase(backupPath, dbPath)
+	})
+}
+
+// UploadRestoreDatabase restores the database from a backup archive or
+// database file uploaded as the multipart field "file"
+func UploadRestoreDatabase(c fiber.Ctx) error {
+	stagedPath, filename, err := stageUpload(c, utils.BackupKindArchive, utils.BackupKindDatabase)
+	if err != nil {
+		return uploadError(c, err)
 	}
+	defer os.Remove(stagedPath)
 
-	if err := backupManager.RestoreDatabase(req.BackupPath, dbPath); err != nil {
+	return restoreDatabase(c, fiber.Map{"upload": filename}, func() error {
+		return backupManager.RestoreDatabase(stagedPath, dbPath)
+	})
+}
+
+// restoreDatabase runs a restore while other requests are held off and
+// records it in the audit trail
+func restoreDatabase(c fiber.Ctx, source fiber.Map, restore func() error) error {
+	// Hold off other requests while the connection pool is swapped
+	err := requestGate.Exclusive(restore)
+	if errors.Is(err, utils.ErrBackupNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Backup not found",
+		})
+	}
+	if errors.Is(err, utils.ErrInvalidBackup) {
+		return c.Status(422).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to restore database",
+			"error": "Failed to restore database: " + err.Error(),
 		})
 	}
 
+	logAudit(c, entityDatabase, 0, database.AuditRestore, nil, source)
+
 	return c.JSON(fiber.Map{
 		"message": "Database restored successfully",
 	})
@@ -73,14 +138,16 @@ func ExportSettings(c fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"message":   "Settings exported successfully",
+		"name":      filepath.Base(filePath),
 		"file_path": filePath,
 	})
 }
 
-// ImportSettings imports library settings from JSON
+// ImportSettings imports library settings from an export in the backup directory
 func ImportSettings(c fiber.Ctx) error {
 	var req struct {
-		FilePath string `json:"file_path"`
+		Name     string `json:"name"`
+		FilePath string `json:"file_path"` // deprecated, must be inside the backup directory
 	}
 
 	if err := c.Bind().JSON(&req); err != nil {
@@ -89,15 +156,115 @@ func ImportSettings(c fiber.Ctx) error {
 		})
 	}
 
-	if err := backupManager.ImportSettings(req.FilePath); err != nil {
+	ref := req.Name
+	if ref == "" {
+		ref = req.FilePath
+	}
+	filePath, err := backupManager.ResolveBackup(ref)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Settings file not found",
+		})
+	}
+
+	return importSettings(c, filePath)
+}
+
+// UploadImportSettings imports library settings from a settings export
+// uploaded as the multipart field "file"
+func UploadImportSettings(c fiber.Ctx) error {
+	stagedPath, _, err := stageUpload(c, utils.BackupKindSettings)
+	if err != nil {
+		return uploadError(c, err)
+	}
+	defer os.Remove(stagedPath)
+
+	return importSettings(c, stagedPath)
+}
+
+// importSettings imports a settings file and records the change. The mode
+// is taken from ?mode=replace|merge (default replace), and ?dry_run=true only
+// reports what would change.
+func importSettings(c fiber.Ctx, filePath string) error {
+	mode := c.Query("mode", utils.ImportReplace)
+	dryRun := c.Query("dry_run") == "true"
+
+	report, err := backupManager.ImportSettings(filePath, mode, dryRun, func(tx *gorm.DB, before, after database.Library) error {
+		return recordAudit(c, tx, entityLibrary, 0, database.AuditImport, before, after)
+	})
+	if errors.Is(err, utils.ErrInvalidImportMode) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, utils.ErrInvalidBackup) {
+		return c.Status(422).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to import settings",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Settings imported successfully",
-	})
+	return c.JSON(report)
+}
+
+// errNoUpload is returned when the multipart field "file" is missing
+var errNoUpload = errors.New(`multipart field "file" is required`)
+
+// stageUpload saves the multipart field "file" into the backup directory and
+// returns the staged path and the uploaded file's name
+func stageUpload(c fiber.Ctx, kinds ...string) (string, string, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return "", "", errNoUpload
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	stagedPath, err := backupManager.StageUpload(header.Filename, file, kinds...)
+	if err != nil {
+		return "", "", err
+	}
+
+	return stagedPath, filepath.Base(header.Filename), nil
+}
+
+// uploadError maps a stageUpload error to a response
+func uploadError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errNoUpload):
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, utils.ErrInvalidBackup):
+		return c.Status(422).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to save upload",
+		})
+	}
+}
+
+// DownloadBackup streams a backup, settings export or snapshot from the
+// backup directory
+func DownloadBackup(c fiber.Ctx) error {
+	path, err := backupManager.BackupPath(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Backup not found",
+		})
+	}
+
+	return c.Download(path)
 }
 
 // ListBackups lists all available backups
@@ -113,3 +280,178 @@ func ListBackups(c fiber.Ctx) error {
 		"backups": backups,
 	})
 }
+
+// ListRemoteBackups lists the backups held by each remote target
+func ListRemoteBackups(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"targets": backupManager.ListRemoteBackups(),
+	})
+}
+
+// VerifyBackup re-checks the checksums of a backup archive against its manifest
+func VerifyBackup(c fiber.Ctx) error {
+	result, err := backupManager.VerifyBackup(c.Params("name"))
+	if errors.Is(err, utils.ErrBackupNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Backup not found",
+		})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// PinBackup protects a backup from retention pruning
+func PinBackup(c fiber.Ctx) error {
+	return setBackupPinned(c, true)
+}
+
+// UnpinBackup makes a pinned backup subject to retention pruning again
+func UnpinBackup(c fiber.Ctx) error {
+	return setBackupPinned(c, false)
+}
+
+// setBackupPinned pins or unpins the backup named in the route
+func setBackupPinned(c fiber.Ctx, pinned bool) error {
+	name := c.Params("name")
+	if err := backupManager.PinBackup(name, pinned); err != nil {
+		if errors.Is(err, utils.ErrBackupNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Backup not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update backup pin",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"name":   name,
+		"pinned": pinned,
+	})
+}
+
+// PruneBackups applies the retention policy, or with ?dry_run=true only
+// reports what would be removed
+func PruneBackups(c fiber.Ctx) error {
+	dryRun := c.Query("dry_run") == "true"
+
+	result, err := backupManager.Prune(dryRun)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to prune backups",
+		})
+	}
+
+	if !dryRun && len(result.Pruned) > 0 {
+		logAudit(c, entityDatabase, 0, database.AuditDelete, nil, fiber.Map{
+			"pruned_backups": len(result.Pruned),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// ExportBundle downloads projects with their components, deployments and
+// checklists plus the library and settings as a portable bundle.
+// ?format=json|ndjson (default json), ?projects=1,2 limits the projects.
+func ExportBundle(c fiber.Ctx) error {
+	format := c.Query("format", utils.BundleJSON)
+	if format != utils.BundleJSON && format != utils.BundleNDJSON {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "format must be json or ndjson",
+		})
+	}
+
+	var projectIDs []uint
+	for _, raw := range strings.Split(c.Query("projects"), ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid project ID: " + raw,
+			})
+		}
+		projectIDs = append(projectIDs, uint(id))
+	}
+
+	var buf bytes.Buffer
+	if err := utils.ExportBundle(&buf, format, projectIDs); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to export bundle",
+		})
+	}
+
+	contentType := fiber.MIMEApplicationJSON
+	if format == utils.BundleNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Attachment("chklst_bundle_" + time.Now().Format("2006-01-02_15-04-05") + "." + format)
+
+	return c.Send(buf.Bytes())
+}
+
+// ImportBundle imports a bundle uploaded as the multipart field "file".
+// ?on_conflict=skip|update (default skip) decides what happens to projects,
+// components and deployments that already exist, and ?dry_run=true only
+// reports what would be created, updated and skipped.
+func ImportBundle(c fiber.Ctx) error {
+	opts := utils.BundleImportOptions{
+		OnConflict: c.Query("on_conflict", utils.BundleSkip),
+		DryRun:     c.Query("dry_run") == "true",
+	}
+	if opts.OnConflict != utils.BundleSkip && opts.OnConflict != utils.BundleUpdate {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "on_conflict must be skip or update",
+		})
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": errNoUpload.Error(),
+		})
+	}
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Failed to read upload",
+		})
+	}
+	defer file.Close()
+
+	bundle, err := utils.DecodeBundle(file)
+	if err != nil {
+		return c.Status(422).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	opts.Record = func(tx *gorm.DB, report *utils.BundleImportReport) error {
+		return recordAudit(c, tx, entityDatabase, 0, database.AuditImport, nil, fiber.Map{
+			"bundle":              filepath.Base(header.Filename),
+			"projects_created":    len(report.Projects.Created),
+			"projects_updated":    len(report.Projects.Updated),
+			"components_created":  len(report.Components.Created),
+			"components_updated":  len(report.Components.Updated),
+			"deployments_created": len(report.Deployments.Created),
+			"deployments_updated": len(report.Deployments.Updated),
+		})
+	}
+
+	report, err := utils.ImportBundle(bundle, opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to import bundle: " + err.Error(),
+		})
+	}
+
+	return c.JSON(report)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/database"
+	"chklst-go/internal/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Audited entity types
+const (
+	entityProject           = "project"
+	entityComponent         = "component"
+	entityDeployment        = "deployment"
+	entityChecklistTemplate = "checklist_template"
+	entityChecklistItem     = "checklist_item"
+	entityLibrary           = "library"
+	entityDeveloper         = "developer"
+	entityServer            = "server"
+	entityEnvironment       = "environment"
+	entitySettings          = "settings"
+	entityDatabase          = "database"
+	entityUser              = "user"
+	entityPermission        = "permission"
+)
+
+// currentActor returns the username of the authenticated caller
+func currentActor(c fiber.Ctx) string {
+	if user := middleware.GetUser(c); user != nil {
+		return user.Username
+	}
+	return "anonymous"
+}
+
+// recordAudit stores an audit event for a mutation made by the current
+// request. tx is the transaction that makes the mutation, so the change and
+// its event are committed together or not at all.
+func recordAudit(c fiber.Ctx, tx *gorm.DB, entityType string, entityID uint, action string, before, after interface{}) error {
+	return database.RecordAudit(tx, database.AuditEntry{
+		Actor:      currentActor(c),
+		RequestID:  middleware.GetRequestID(c),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Before:     before,
+		After:      after,
+	})
+}
+
+// saveAudited saves model like database.SaveVersion and records the update
+// in the same transaction
+func saveAudited(c fiber.Ctx, entityType string, entityID uint, before interface{}, model interface{}, version *uint, expected uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.SaveVersion(tx, model, version, expected); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityType, entityID, database.AuditUpdate, before, model)
+	})
+}
+
+// logAudit stores an audit event for a change outside the database, such as
+// a restore or a deleted backup file. The change cannot be undone, so a
+// failure to record it is logged rather than failing the request.
+func logAudit(c fiber.Ctx, entityType string, entityID uint, action string, before, after interface{}) {
+	if err := recordAudit(c, database.DB, entityType, entityID, action, before, after); err != nil {
+		utils.AppLogger.WithRequestID(middleware.GetRequestID(c)).Error("Failed to record audit event", err, map[string]interface{}{
+			"entity_type": entityType,
+			"entity_id":   entityID,
+			"action":      action,
+		})
+	}
+}
+
+// ListAuditEvents returns audit events with optional filtering
+func ListAuditEvents(c fiber.Ctx) error {
+	query := database.DB.Model(&database.AuditEvent{})
+
+	if entity := c.Query("entity"); entity != "" {
+		query = query.Where("entity_type = ?", entity)
+	}
+
+	if entityID := c.Query("entity_id"); entityID != "" {
+		id, err := strconv.Atoi(entityID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid entity_id",
+			})
+		}
+		query = query.Where("entity_id = ?", id)
+	}
+
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor = ?", actor)
+	}
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	if requestID := c.Query("request_id"); requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
+
+	if from := c.Query("from"); from != "" {
+		fromTime, err := parseTimestamp(from)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid from timestamp",
+			})
+		}
+		query = query.Where("timestamp >= ?", fromTime)
+	}
+
+	if to := c.Query("to"); to != "" {
+		toTime, err := parseTimestamp(to)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid to timestamp",
+			})
+		}
+		query = query.Where("timestamp < ?", toTime)
+	}
+
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "limit must be between 1 and 1000",
+			})
+		}
+		limit = parsed
+	}
+
+	var events []database.AuditEvent
+	if err := query.Order("timestamp DESC, id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch audit events",
+		})
+	}
+
+	return c.JSON(events)
+}
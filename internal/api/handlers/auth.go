@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/auth"
+	"chklst-go/internal/database"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// minPasswordLength is the minimum accepted password length
+const minPasswordLength = 8
+
+var tokenIssuer *auth.TokenIssuer
+
+// InitAuthHandlers initializes the auth handlers
+func InitAuthHandlers(tokens *auth.TokenIssuer) {
+	tokenIssuer = tokens
+	auth.DummyHash() // So the first unknown username is not slower to reject
+}
+
+// Login verifies credentials and issues a session token
+func Login(c fiber.Ctx) error {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// The password is checked even when the user is unknown or disabled, so
+	// every failed login takes as long
+	var user database.User
+	found := database.DB.Where("username = ?", req.Username).First(&user).Error == nil
+	hash := user.PasswordHash
+	if !found {
+		hash = auth.DummyHash()
+	}
+	if !auth.CheckPassword(hash, req.Password) || !found || user.Disabled {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid username or password",
+		})
+	}
+
+	token, expiresAt, err := tokenIssuer.Issue(user.ID, user.Username)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to issue token",
+		})
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	database.DB.Model(&user).Update("last_login_at", now)
+
+	return c.JSON(fiber.Map{
+		"token":      token,
+		"expires_at": expiresAt,
+		"user":       user,
+	})
+}
+
+// GetCurrentUser returns the authenticated user
+func GetCurrentUser(c fiber.Ctx) error {
+	return c.JSON(middleware.GetUser(c))
+}
+
+// ChangePassword changes the password of the authenticated user and signs
+// out every session of the user, including the current one
+func ChangePassword(c fiber.Ctx) error {
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	user := middleware.GetUser(c)
+	if !auth.CheckPassword(user.PasswordHash, req.CurrentPassword) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Current password is incorrect",
+		})
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Password must be at least 8 characters",
+		})
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update password",
+		})
+	}
+
+	before := *user
+	now := time.Now()
+	user.PasswordHash = hash
+	user.PasswordChangedAt = &now
+
+	if err := saveAudited(c, entityUser, user.ID, before, user, &user.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update password")
+	}
+
+	return c.SendStatus(204)
+}
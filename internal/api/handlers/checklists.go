@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/database"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// ListChecklistTemplates returns all checklist templates with their items
+func ListChecklistTemplates(c fiber.Ctx) error {
+	query := database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC, id ASC")
+	})
+
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+
+	var templates []database.ChecklistTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch checklist templates",
+		})
+	}
+
+	return c.JSON(templates)
+}
+
+// GetChecklistTemplate returns a single checklist template by ID
+func GetChecklistTemplate(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid checklist template ID",
+		})
+	}
+
+	var template database.ChecklistTemplate
+	if err := database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC, id ASC")
+	}).First(&template, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Checklist template not found",
+		})
+	}
+
+	setETag(c, template.Version)
+	return c.JSON(template)
+}
+
+// CreateChecklistTemplate creates a checklist template together with its items
+func CreateChecklistTemplate(c fiber.Ctx) error {
+	var template database.ChecklistTemplate
+
+	if err := c.Bind().JSON(&template); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if template.Name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Checklist template name is required",
+		})
+	}
+
+	template.ID = 0
+	for i := range template.Items {
+		template.Items[i].ID = 0
+		if template.Items[i].Position == 0 {
+			template.Items[i].Position = i + 1
+		}
+		if template.Items[i].Title == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Checklist item title is required",
+			})
+		}
+	}
+
+	if ok, err := validate(c, &template); !ok {
+		return err
+	}
+
+	template.Version = 1
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityChecklistTemplate, template.ID, database.AuditCreate, nil, template)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create checklist template",
+		})
+	}
+
+	setETag(c, template.Version)
+	return c.Status(201).JSON(template)
+}
+
+// UpdateChecklistTemplate replaces a checklist template and its items.
+// Checklists already created for deployments are not affected.
+func UpdateChecklistTemplate(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid checklist template ID",
+		})
+	}
+
+	var template database.ChecklistTemplate
+	if err := database.DB.First(&template, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Checklist template not found",
+		})
+	}
+
+	if etag := versionETag(template.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	var req database.ChecklistTemplate
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Checklist template name is required",
+		})
+	}
+
+	before := template
+
+	template.Name = req.Name
+	template.ProjectID = req.ProjectID
+	template.ComponentID = req.ComponentID
+	template.Description = req.Description
+
+	if ok, err := validateChanges(c, &before, &template); !ok {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.SaveVersion(tx, &template, &template.Version, before.Version); err != nil {
+			return err
+		}
+
+		template.Items = req.Items
+		if err := replaceTemplateItems(tx, &template); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityChecklistTemplate, template.ID, database.AuditUpdate, before, template)
+	})
+	if err != nil {
+		return versionConflict(c, err, "Failed to update checklist template")
+	}
+
+	setETag(c, template.Version)
+	return c.JSON(template)
+}
+
+// checklistTemplatePatchFields are the checklist template fields PATCH may
+// change. A patched item list replaces the template's items.
+var checklistTemplatePatchFields = []string{
+	"name", "project_id", "component_id", "description", "items",
+}
+
+// PatchChecklistTemplate applies a JSON merge patch to a checklist template.
+// Checklists already created for deployments are not affected.
+func PatchChecklistTemplate(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid checklist template ID",
+		})
+	}
+
+	var template database.ChecklistTemplate
+	err = database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC, id ASC")
+	}).First(&template, id).Error
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Checklist template not found",
+		})
+	}
+
+	if etag := versionETag(template.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := template
+
+	changed, err := applyMergePatch(c.Body(), &template, checklistTemplatePatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityChecklistTemplate, template.Version, changed, template)
+	}
+
+	if template.Name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Checklist template name is required",
+		})
+	}
+
+	replaceItems := containsString(changed, "items")
+	for i := range template.Items {
+		if template.Items[i].Title == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Checklist item title is required",
+			})
+		}
+	}
+
+	if ok, err := validate(c, &template, changed...); !ok {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.SaveVersion(tx, &template, &template.Version, before.Version); err != nil {
+			return err
+		}
+		if replaceItems {
+			if err := replaceTemplateItems(tx, &template); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, tx, entityChecklistTemplate, template.ID, database.AuditUpdate, before, template)
+	})
+	if err != nil {
+		return versionConflict(c, err, "Failed to update checklist template")
+	}
+
+	return patched(c, entityChecklistTemplate, template.Version, changed, template)
+}
+
+// replaceTemplateItems replaces the stored items of a template with its
+// Items, numbering them when they have no position
+func replaceTemplateItems(tx *gorm.DB, template *database.ChecklistTemplate) error {
+	if err := tx.Where("template_id = ?", template.ID).Delete(&database.ChecklistTemplateItem{}).Error; err != nil {
+		return err
+	}
+
+	for i := range template.Items {
+		template.Items[i].ID = 0
+		template.Items[i].TemplateID = template.ID
+		template.Items[i].Version = 1
+		if template.Items[i].Position == 0 {
+			template.Items[i].Position = i + 1
+		}
+	}
+
+	if len(template.Items) == 0 {
+		return nil
+	}
+	return tx.Create(&template.Items).Error
+}
+
+// DeleteChecklistTemplate deletes a checklist template
+func DeleteChecklistTemplate(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid checklist template ID",
+		})
+	}
+
+	var template database.ChecklistTemplate
+	found := database.DB.First(&template, id).Error == nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", id).Delete(&database.ChecklistTemplateItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&database.ChecklistTemplate{}, id).Error; err != nil || !found {
+			return err
+		}
+		return recordAudit(c, tx, entityChecklistTemplate, template.ID, database.AuditDelete, template, nil)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete checklist template",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// GetDeploymentChecklist returns the checklist of a deployment
+func GetDeploymentChecklist(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid deployment ID",
+		})
+	}
+
+	var items []database.ChecklistItem
+	if err := database.DB.Where("deployment_id = ?", id).Order("position ASC, id ASC").Find(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch checklist",
+		})
+	}
+
+	return c.JSON(items)
+}
+
+// TickChecklistItem marks a checklist item as done, recording who ticked it and when
+func TickChecklistItem(c fiber.Ctx) error {
+	now := time.Now()
+	return setChecklistItemState(c, true, middleware.GetUser(c).Name(), &now)
+}
+
+// UntickChecklistItem reopens a checklist item
+func UntickChecklistItem(c fiber.Ctx) error {
+	return setChecklistItemState(c, false, "", nil)
+}
+
+// setChecklistItemState updates the checked state of a deployment checklist
+// item at the version named by If-Match
+func setChecklistItemState(c fiber.Ctx, checked bool, checkedBy string, checkedAt *time.Time) error {
+	deploymentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid deployment ID",
+		})
+	}
+
+	itemID, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid checklist item ID",
+		})
+	}
+
+	var item database.ChecklistItem
+	if err := database.DB.Where("deployment_id = ?", deploymentID).First(&item, itemID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Checklist item not found",
+		})
+	}
+
+	if etag := versionETag(item.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := item
+
+	item.Checked = checked
+	item.CheckedBy = checkedBy
+	item.CheckedAt = checkedAt
+	item.Version++
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only if nobody ticked or unticked the item since it was loaded
+		result := tx.Model(&item).Where("version = ?", before.Version).
+			Select("checked", "checked_by", "checked_at", "version").Updates(&item)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return database.ErrVersionConflict
+		}
+		return recordAudit(c, tx, entityChecklistItem, item.ID, database.AuditUpdate, before, item)
+	})
+	if err != nil {
+		return versionConflict(c, err, "Failed to update checklist item")
+	}
+
+	setETag(c, item.Version)
+	return c.JSON(item)
+}
+
+// findChecklistTemplate picks the most specific template for a deployment:
+// component template first, then project template, then the global default
+func findChecklistTemplate(tx *gorm.DB, projectID uint, componentID *uint) (*database.ChecklistTemplate, error) {
+	var template database.ChecklistTemplate
+
+	if componentID != nil {
+		err := tx.Where("component_id = ?", *componentID).Order("id ASC").First(&template).Error
+		if err == nil {
+			return &template, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	err := tx.Where("project_id = ? AND component_id IS NULL", projectID).Order("id ASC").First(&template).Error
+	if err == nil {
+		return &template, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	err = tx.Where("project_id IS NULL AND component_id IS NULL").Order("id ASC").First(&template).Error
+	if err == nil {
+		return &template, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// createDeploymentChecklist instantiates checklist items for a new deployment.
+// An explicit templateID wins over the automatic template lookup.
+func createDeploymentChecklist(tx *gorm.DB, deployment *database.Deployment, templateID *uint) error {
+	var template *database.ChecklistTemplate
+
+	if templateID != nil {
+		var explicit database.ChecklistTemplate
+		if err := tx.First(&explicit, *templateID).Error; err != nil {
+			return err
+		}
+		template = &explicit
+	} else {
+		found, err := findChecklistTemplate(tx, deployment.ProjectID, deployment.ComponentID)
+		if err != nil {
+			return err
+		}
+		template = found
+	}
+
+	if template == nil {
+		return nil
+	}
+
+	var templateItems []database.ChecklistTemplateItem
+	if err := tx.Where("template_id = ?", template.ID).Order("position ASC, id ASC").Find(&templateItems).Error; err != nil {
+		return err
+	}
+
+	if len(templateItems) == 0 {
+		return nil
+	}
+
+	items := make([]database.ChecklistItem, 0, len(templateItems))
+	for i, ti := range templateItems {
+		templateItemID := ti.ID
+		items = append(items, database.ChecklistItem{
+			DeploymentID:   deployment.ID,
+			TemplateItemID: &templateItemID,
+			Position:       i + 1,
+			Title:          ti.Title,
+			Description:    ti.Description,
+			Required:       ti.Required,
+		})
+	}
+
+	return tx.Create(&items).Error
+}
+
+// openRequiredChecklistItems returns required checklist items that are not yet ticked
+func openRequiredChecklistItems(tx *gorm.DB, deploymentID uint) ([]database.ChecklistItem, error) {
+	var items []database.ChecklistItem
+	err := tx.Where("deployment_id = ? AND required = ? AND checked = ?", deploymentID, true, false).
+		Order("position ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
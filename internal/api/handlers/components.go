@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
 // CreateComponent creates a new component for a project
@@ -25,12 +26,24 @@ func CreateComponent(c fiber.Ctx) error {
 
 	component.ProjectID = uint(projectID)
 
-	if err := database.DB.Create(&component).Error; err != nil {
+	if ok, err := validate(c, &component); !ok {
+		return err
+	}
+
+	component.Version = 1
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&component).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityComponent, component.ID, database.AuditCreate, nil, component)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create component",
 		})
 	}
 
+	setETag(c, component.Version)
 	return c.Status(201).JSON(component)
 }
 
@@ -63,23 +76,103 @@ func UpdateComponent(c fiber.Ctx) error {
 		})
 	}
 
-	if err := c.Bind().JSON(&component); err != nil {
+	if etag := versionETag(component.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := component
+
+	if ok, err := bindJSON(c, &component); !ok {
+		return err
+	}
+	component.ID = before.ID
+	component.ProjectID = before.ProjectID   // Stays in the project it was created in
+	component.ExternalID = before.ExternalID // Stable across instances, never reassigned
+
+	if ok, err := validateChanges(c, &before, &component); !ok {
+		return err
+	}
+
+	if err := saveAudited(c, entityComponent, component.ID, before, &component, &component.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update component")
+	}
+
+	setETag(c, component.Version)
+	return c.JSON(component)
+}
+
+// componentPatchFields are the component fields PATCH may change. A
+// component stays in the project it was created in.
+var componentPatchFields = []string{
+	"name", "developer", "vcs_type", "vcs_url", "build_command",
+	"component_url", "enabled", "description",
+}
+
+// PatchComponent applies a JSON merge patch to a component
+func PatchComponent(c fiber.Ctx) error {
+	projectID, err := strconv.Atoi(c.Params("projectId"))
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
+			"error": "Invalid project ID",
 		})
 	}
 
-	if err := database.DB.Save(&component).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update component",
+	componentID, err := strconv.Atoi(c.Params("componentId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid component ID",
 		})
 	}
 
-	return c.JSON(component)
+	var component database.Component
+	if err := database.DB.First(&component, componentID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Component not found",
+		})
+	}
+
+	if component.ProjectID != uint(projectID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Component does not belong to this project",
+		})
+	}
+
+	if etag := versionETag(component.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := component
+
+	changed, err := applyMergePatch(c.Body(), &component, componentPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityComponent, component.Version, changed, component)
+	}
+
+	if ok, err := validate(c, &component, changed...); !ok {
+		return err
+	}
+
+	if err := saveAudited(c, entityComponent, component.ID, before, &component, &component.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update component")
+	}
+
+	return patched(c, entityComponent, component.Version, changed, component)
 }
 
-// DeleteComponent deletes a component
+// DeleteComponent deletes a component with its deployments and checklist
+// templates. A component of another project is not found, as access is
+// granted per project.
 func DeleteComponent(c fiber.Ctx) error {
+	projectID, err := strconv.Atoi(c.Params("projectId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
 	componentID, err := strconv.Atoi(c.Params("componentId"))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
@@ -87,7 +180,20 @@ func DeleteComponent(c fiber.Ctx) error {
 		})
 	}
 
-	if err := database.DB.Delete(&database.Component{}, componentID).Error; err != nil {
+	var component database.Component
+	if err := database.DB.First(&component, componentID).Error; err != nil || component.ProjectID != uint(projectID) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Component not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.DeleteComponent(tx, component.ID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityComponent, component.ID, database.AuditDelete, component, nil)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete component",
 		})
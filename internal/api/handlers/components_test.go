@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"testing"
+
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/auth"
+	"chklst-go/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// scopedComponentsApp serves the component routes, guarded like the router
+// does, for a release manager limited to project 1
+func scopedComponentsApp() *fiber.App {
+	projectID := uint(1)
+	grants := []database.Permission{{Role: string(auth.RoleReleaseManager), ProjectID: &projectID}}
+
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals("permissions", grants)
+		return c.Next()
+	})
+
+	manageComponents := middleware.NewGuard(nil).RequireScoped(auth.PermManageProjects, middleware.ProjectParam("projectId"))
+	app.Put("/projects/:projectId/components/:componentId", UpdateComponent, manageComponents)
+	app.Patch("/projects/:projectId/components/:componentId", PatchComponent, manageComponents)
+	app.Delete("/projects/:projectId/components/:componentId", DeleteComponent, manageComponents)
+	return app
+}
+
+// newProjectsWithComponents creates projects 1 and 2 with components 1 and
+// 2 in them
+func newProjectsWithComponents(t *testing.T) {
+	t.Helper()
+	create(t,
+		&database.Project{Name: "billing", Version: 1},
+		&database.Project{Name: "payroll", Version: 1},
+		&database.Component{ProjectID: 1, Name: "api", Version: 1},
+		&database.Component{ProjectID: 2, Name: "worker", Version: 1},
+	)
+}
+
+func TestScopedComponentAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"update own", "PUT", "/projects/1/components/1", `{"name":"api2"}`, 200},
+		{"patch own", "PATCH", "/projects/1/components/1", `{"name":"api2"}`, 200},
+		{"delete own", "DELETE", "/projects/1/components/1", ``, 204},
+		{"update in other project", "PUT", "/projects/2/components/2", `{"name":"x"}`, 403},
+		{"delete in other project", "DELETE", "/projects/2/components/2", ``, 403},
+		{"update other project's component through own project", "PUT", "/projects/1/components/2", `{"name":"x"}`, 400},
+		{"patch other project's component through own project", "PATCH", "/projects/1/components/2", `{"name":"x"}`, 400},
+		{"delete other project's component through own project", "DELETE", "/projects/1/components/2", ``, 404},
+		{"delete unknown component", "DELETE", "/projects/1/components/99", ``, 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			newProjectsWithComponents(t)
+
+			resp := send(t, scopedComponentsApp(), tt.method, tt.path, tt.body, nil, nil)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+			}
+
+			var other database.Component
+			if err := database.DB.First(&other, 2).Error; err != nil {
+				t.Fatalf("project 2's component is gone: %v", err)
+			}
+			if other.Name != "worker" || other.ProjectID != 2 {
+				t.Fatalf("project 2's component changed: %+v", other)
+			}
+		})
+	}
+}
+
+func TestComponentPutKeepsProject(t *testing.T) {
+	openTestDB(t)
+	newProjectsWithComponents(t)
+
+	var component database.Component
+	resp := send(t, scopedComponentsApp(), "PUT", "/projects/1/components/1", `{"name":"api","project_id":2}`, nil, &component)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var stored database.Component
+	database.DB.First(&stored, 1)
+	if component.ProjectID != 1 || stored.ProjectID != 1 {
+		t.Fatalf("component moved to project %d (stored %d), want it to stay in 1", component.ProjectID, stored.ProjectID)
+	}
+}
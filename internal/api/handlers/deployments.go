@@ -1,34 +1,86 @@
 package handlers
 
 import (
+	"chklst-go/internal/api/middleware"
 	"chklst-go/internal/database"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
-// ListDeployments returns all deployments with optional filtering
+// Pagination defaults for ListDeployments, when a page is asked for
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// deploymentSortColumns maps sort keys to columns
+var deploymentSortColumns = map[string]string{
+	"timestamp":   "timestamp",
+	"environment": "environment",
+	"status":      "status",
+	"created_at":  "created_at",
+	"id":          "id",
+}
+
+// ListDeployments returns deployments with filtering and sorting. With page
+// or page_size the body is that page and X-Page and X-Page-Size describe it;
+// without either it is every match. X-Total-Count is always sent.
 func ListDeployments(c fiber.Ctx) error {
-	query := database.DB.Preload("Project").Preload("Component")
+	query, err := filterDeployments(c, database.DB.Model(&database.Deployment{}))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	// Filter by project
-	if projectID := c.Query("project_id"); projectID != "" {
-		query = query.Where("project_id = ?", projectID)
+	page, err := queryInt(c, "page", 1)
+	if err != nil || page < 1 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "page must be a positive integer",
+		})
 	}
 
-	// Filter by month/year
-	if month := c.Query("month"); month != "" {
-		if year := c.Query("year"); year != "" {
-			monthInt, _ := strconv.Atoi(month)
-			yearInt, _ := strconv.Atoi(year)
-			
-			startDate := time.Date(yearInt, time.Month(monthInt), 1, 0, 0, 0, 0, time.UTC)
-			endDate := startDate.AddDate(0, 1, 0)
-			
-			query = query.Where("timestamp >= ? AND timestamp < ?", startDate, endDate)
-		}
+	pageSize, err := queryInt(c, "page_size", defaultPageSize)
+	if err != nil || pageSize < 1 || pageSize > maxPageSize {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("page_size must be between 1 and %d", maxPageSize),
+		})
+	}
+
+	sortColumn, ok := deploymentSortColumns[c.Query("sort", "timestamp")]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "sort must be one of timestamp, environment, status, created_at, id",
+		})
+	}
+
+	order := strings.ToLower(c.Query("order", "desc"))
+	if order != "asc" && order != "desc" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "order must be asc or desc",
+		})
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch deployments",
+		})
+	}
+
+	query = query.Preload("Project").Preload("Component").
+		Order(fmt.Sprintf("%s %s, id %s", sortColumn, order, order))
+
+	// Callers that predate paging expect the whole list
+	paged := c.Query("page") != "" || c.Query("page_size") != ""
+	if paged {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
 	}
 
 	var deployments []database.Deployment
@@ -38,9 +90,95 @@ func ListDeployments(c fiber.Ctx) error {
 		})
 	}
 
+	c.Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if paged {
+		c.Set("X-Page", strconv.Itoa(page))
+		c.Set("X-Page-Size", strconv.Itoa(pageSize))
+	}
+
 	return c.JSON(deployments)
 }
 
+// filterDeployments applies the deployment list query-string filters
+func filterDeployments(c fiber.Ctx, query *gorm.DB) (*gorm.DB, error) {
+	// ID filters
+	for _, column := range []string{"project_id", "component_id"} {
+		if value := c.Query(column); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s", column)
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+
+	// Exact-match text filters
+	for _, column := range []string{"environment", "developer_name", "deployed_by", "jira_id"} {
+		if value := c.Query(column); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	// Status filter, comma-separated for several states
+	if value := c.Query("status"); value != "" {
+		var statuses []database.DeploymentStatus
+		for _, status := range strings.Split(value, ",") {
+			status := database.DeploymentStatus(strings.TrimSpace(status))
+			if !status.IsValid() {
+				return nil, fmt.Errorf("Invalid status: %s", status)
+			}
+			statuses = append(statuses, status)
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+
+	// Filter by month/year
+	month, year := c.Query("month"), c.Query("year")
+	if month != "" || year != "" {
+		monthInt, err := strconv.Atoi(month)
+		if err != nil || monthInt < 1 || monthInt > 12 {
+			return nil, errors.New("month must be between 1 and 12 and used with year")
+		}
+		yearInt, err := strconv.Atoi(year)
+		if err != nil {
+			return nil, errors.New("year must be a number and used with month")
+		}
+
+		startDate := time.Date(yearInt, time.Month(monthInt), 1, 0, 0, 0, 0, time.UTC)
+		endDate := startDate.AddDate(0, 1, 0)
+
+		query = query.Where("timestamp >= ? AND timestamp < ?", startDate, endDate)
+	}
+
+	// Arbitrary date range
+	if from := c.Query("from"); from != "" {
+		fromTime, err := parseTimestamp(from)
+		if err != nil {
+			return nil, errors.New("Invalid from timestamp")
+		}
+		query = query.Where("timestamp >= ?", fromTime)
+	}
+
+	if to := c.Query("to"); to != "" {
+		toTime, err := parseTimestamp(to)
+		if err != nil {
+			return nil, errors.New("Invalid to timestamp")
+		}
+		query = query.Where("timestamp < ?", toTime)
+	}
+
+	return query, nil
+}
+
+// queryInt parses an integer query parameter with a default
+func queryInt(c fiber.Ctx, key string, fallback int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
 // GetDeployment returns a single deployment by ID
 func GetDeployment(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -51,34 +189,34 @@ func GetDeployment(c fiber.Ctx) error {
 	}
 
 	var deployment database.Deployment
-	if err := database.DB.Preload("Project").Preload("Component").First(&deployment, id).Error; err != nil {
+	if err := preloadDeployment(database.DB).First(&deployment, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Deployment not found",
 		})
 	}
 
+	setETag(c, deployment.Version)
 	return c.JSON(deployment)
 }
 
 // DeploymentRequest is used for creating deployments with flexible timestamp parsing
 type DeploymentRequest struct {
-	JiraID             string  `json:"jira_id"`
-	ProjectID          uint    `json:"project_id"`
-	ComponentID        *uint   `json:"component_id"`
-	Timestamp          string  `json:"timestamp"` // Accept as string for flexible parsing
-	Environment        string  `json:"environment"`
-	VCSURL             string  `json:"vcs_url"`
-	DeveloperName      string  `json:"developer_name"`
-	BuildServer        string  `json:"build_server"`
-	DeployServer       string  `json:"deploy_server"`
-	DatabaseName       string  `json:"database_name"`
-	DBBackupLocation   string  `json:"db_backup_location"`
-	DatabaseScript     string  `json:"database_script"`
+	JiraID              string `json:"jira_id" validate:"jira"`
+	ProjectID           uint   `json:"project_id" validate:"required,exists=projects"`
+	ComponentID         *uint  `json:"component_id" validate:"exists=components,belongs=project_id:ProjectID"`
+	Timestamp           string `json:"timestamp"` // Accept as string for flexible parsing
+	Environment         string `json:"environment" validate:"library=environments"`
+	VCSURL              string `json:"vcs_url"`
+	DeveloperName       string `json:"developer_name" validate:"library=developers"`
+	BuildServer         string `json:"build_server" validate:"library=build_servers"`
+	DeployServer        string `json:"deploy_server" validate:"library=deploy_servers"`
+	DatabaseName        string `json:"database_name"`
+	DBBackupLocation    string `json:"db_backup_location"`
+	DatabaseScript      string `json:"database_script"`
 	PreviousBuildBackup string `json:"previous_build_backup"`
-	BuildStatus        string  `json:"build_status"`
-	DeployStatus       string  `json:"deploy_status"`
-	Notes              string  `json:"notes"`
-	DeployedBy         string  `json:"deployed_by"`
+	Notes               string `json:"notes"`
+	DeployedBy          string `json:"deployed_by"`
+	ChecklistTemplateID *uint  `json:"checklist_template_id" validate:"exists=checklist_templates"` // Optional, overrides template lookup
 }
 
 // parseTimestamp flexibly parses timestamp in multiple formats
@@ -125,6 +263,10 @@ func CreateDeployment(c fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := validate(c, &req); !ok {
+		return err
+	}
+
 	// Parse timestamp flexibly
 	timestamp, err := parseTimestamp(req.Timestamp)
 	if err != nil {
@@ -148,21 +290,42 @@ func CreateDeployment(c fiber.Ctx) error {
 		DBBackupLocation:    req.DBBackupLocation,
 		DatabaseScript:      req.DatabaseScript,
 		PreviousBuildBackup: req.PreviousBuildBackup,
-		BuildStatus:         req.BuildStatus,
-		DeployStatus:        req.DeployStatus,
 		Notes:               req.Notes,
 		DeployedBy:          req.DeployedBy,
 	}
 
-	if err := database.DB.Create(&deployment).Error; err != nil {
+	// The authenticated caller is the one recording the deployment
+	if user := middleware.GetUser(c); user != nil {
+		deployment.DeployedBy = user.Name()
+	}
+
+	// Every deployment starts at the beginning of the lifecycle
+	deployment.ApplyStatus(database.StatusPending)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&deployment).Error; err != nil {
+			return err
+		}
+		if err := createDeploymentChecklist(tx, &deployment, req.ChecklistTemplateID); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityDeployment, deployment.ID, database.AuditCreate, nil, deployment)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Checklist template not found",
+			})
+		}
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create deployment",
 		})
 	}
 
 	// Preload relationships
-	database.DB.Preload("Project").Preload("Component").First(&deployment, deployment.ID)
+	preloadDeployment(database.DB).First(&deployment, deployment.ID)
 
+	setETag(c, deployment.Version)
 	return c.Status(201).JSON(deployment)
 }
 
@@ -182,22 +345,198 @@ func UpdateDeployment(c fiber.Ctx) error {
 		})
 	}
 
-	if err := c.Bind().JSON(&deployment); err != nil {
+	if etag := versionETag(deployment.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := deployment
+
+	// Lifecycle state may only change through TransitionDeployment,
+	// DeployedBy always stays the user who recorded the deployment
+	// and ExternalID never changes
+	status, buildStatus, deployStatus := deployment.Status, deployment.BuildStatus, deployment.DeployStatus
+	deployedBy := deployment.DeployedBy
+
+	if ok, err := bindJSON(c, &deployment); !ok {
+		return err
+	}
+
+	deployment.Status, deployment.BuildStatus, deployment.DeployStatus = status, buildStatus, deployStatus
+	deployment.DeployedBy = deployedBy
+	deployment.ID = before.ID
+	deployment.ExternalID = before.ExternalID
+	deployment.Checklist = nil // Checklist items change only through the tick endpoints
+
+	if ok, err := validateChanges(c, &before, &deployment); !ok {
+		return err
+	}
+
+	if err := saveAudited(c, entityDeployment, deployment.ID, before, &deployment, &deployment.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update deployment")
+	}
+
+	setETag(c, deployment.Version)
+	return c.JSON(deployment)
+}
+
+// deploymentPatchFields are the deployment fields PATCH may change.
+// Lifecycle state changes only through TransitionDeployment and checklist
+// items only through the tick endpoints.
+var deploymentPatchFields = []string{
+	"jira_id", "timestamp", "project_id", "component_id", "environment",
+	"vcs_url", "developer_name", "build_server", "deploy_server",
+	"database_name", "db_backup_location", "database_script",
+	"previous_build_backup", "notes",
+}
+
+// PatchDeployment applies a JSON merge patch to a deployment
+func PatchDeployment(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid deployment ID",
+		})
+	}
+
+	var deployment database.Deployment
+	if err := database.DB.First(&deployment, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Deployment not found",
+		})
+	}
+
+	if etag := versionETag(deployment.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := deployment
+
+	changed, err := applyMergePatch(c.Body(), &deployment, deploymentPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityDeployment, deployment.Version, changed, deployment)
+	}
+
+	if ok, err := validate(c, &deployment, changed...); !ok {
+		return err
+	}
+
+	if err := saveAudited(c, entityDeployment, deployment.ID, before, &deployment, &deployment.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update deployment")
+	}
+
+	return patched(c, entityDeployment, deployment.Version, changed, deployment)
+}
+
+// errOpenChecklistItems aborts a transition to deployed while required
+// checklist items are open
+var errOpenChecklistItems = errors.New("required checklist items are still open")
+
+// TransitionDeployment moves a deployment to the next lifecycle state
+func TransitionDeployment(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid deployment ID",
+		})
+	}
+
+	var req struct {
+		Status database.DeploymentStatus `json:"status"`
+	}
+
+	if err := c.Bind().JSON(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
 
-	if err := database.DB.Save(&deployment).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update deployment",
+	var deployment database.Deployment
+	if err := database.DB.First(&deployment, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Deployment not found",
+		})
+	}
+
+	if etag := versionETag(deployment.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	if !req.Status.IsValid() {
+		return c.Status(400).JSON(fiber.Map{
+			"error":          fmt.Sprintf("Unknown deployment status: %q", req.Status),
+			"current_status": deployment.Status,
+			"allowed":        deployment.Status.AllowedTransitions(),
 		})
 	}
 
+	if !deployment.Status.CanTransitionTo(req.Status) {
+		return c.Status(409).JSON(fiber.Map{
+			"error":          fmt.Sprintf("Cannot transition deployment from %s to %s", deployment.Status, req.Status),
+			"current_status": deployment.Status,
+			"allowed":        deployment.Status.AllowedTransitions(),
+		})
+	}
+
+	before := deployment
+	deployment.ApplyStatus(req.Status)
+	deployment.Version++
+
+	var openItems []database.ChecklistItem
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Required checklist items must be ticked before a deployment counts
+		// as deployed. They are checked in the transaction that saves the
+		// status so an item cannot be unticked in between.
+		if req.Status == database.StatusDeployed {
+			items, err := openRequiredChecklistItems(tx, deployment.ID)
+			if err != nil {
+				return err
+			}
+			if len(items) > 0 {
+				openItems = items
+				return errOpenChecklistItems
+			}
+		}
+
+		// Only touch the lifecycle columns so concurrent edits to other fields
+		// survive, and only if no other transition got there first
+		result := tx.Model(&deployment).Where("version = ?", before.Version).
+			Select("status", "build_status", "deploy_status", "version").Updates(&deployment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return database.ErrVersionConflict
+		}
+		return recordAudit(c, tx, entityDeployment, deployment.ID, database.AuditTransition, before, deployment)
+	})
+	if errors.Is(err, errOpenChecklistItems) {
+		return c.Status(409).JSON(fiber.Map{
+			"error":          "Required checklist items are still open",
+			"current_status": before.Status,
+			"open_items":     openItems,
+		})
+	}
+	if err != nil {
+		return versionConflict(c, err, "Failed to update deployment")
+	}
+
+	preloadDeployment(database.DB).First(&deployment, deployment.ID)
+
+	setETag(c, deployment.Version)
 	return c.JSON(deployment)
 }
 
-// DeleteDeployment deletes a deployment
+// preloadDeployment preloads the relationships returned with a single deployment
+func preloadDeployment(db *gorm.DB) *gorm.DB {
+	return db.Preload("Project").Preload("Component").Preload("Checklist", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC, id ASC")
+	})
+}
+
+// DeleteDeployment deletes a deployment and its checklist
 func DeleteDeployment(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -206,7 +545,16 @@ func DeleteDeployment(c fiber.Ctx) error {
 		})
 	}
 
-	if err := database.DB.Delete(&database.Deployment{}, id).Error; err != nil {
+	var deployment database.Deployment
+	found := database.DB.First(&deployment, id).Error == nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.DeleteDeployment(tx, uint(id)); err != nil || !found {
+			return err
+		}
+		return recordAudit(c, tx, entityDeployment, deployment.ID, database.AuditDelete, deployment, nil)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete deployment",
 		})
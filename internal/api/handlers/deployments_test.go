@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chklst-go/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB points database.DB at a migrated database in a temporary
+// directory
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDatabase(filepath.Join(t.TempDir(), "chklst.db")); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	database.DB.Logger = logger.Discard
+	t.Cleanup(func() { database.CloseDatabase() })
+
+	if _, err := database.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+}
+
+// create inserts rows into the test database
+func create(t *testing.T, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := database.DB.Create(row).Error; err != nil {
+			t.Fatalf("failed to create %T: %v", row, err)
+		}
+	}
+}
+
+// send runs a request against app and decodes a JSON response into out
+func send(t *testing.T, app *fiber.App, method string, path string, body string, header map[string]string, out interface{}) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+// newDeployment creates a project and a deployment in status with the
+// given checklist
+func newDeployment(t *testing.T, status database.DeploymentStatus, items ...database.ChecklistItem) database.Deployment {
+	t.Helper()
+	project := database.Project{Name: "billing", Version: 1}
+	create(t, &project)
+
+	deployment := database.Deployment{ProjectID: project.ID, JiraID: "PAT-1", Version: 1}
+	deployment.ApplyStatus(status)
+	create(t, &deployment)
+
+	for i := range items {
+		items[i].DeploymentID = deployment.ID
+		items[i].Version = 1
+		create(t, &items[i])
+	}
+	return deployment
+}
+
+func TestTransitionDeployment(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   database.DeploymentStatus
+		to     string
+		status int
+	}{
+		{"next state", database.StatusPending, "building", 200},
+		{"failure", database.StatusDeploying, "failed", 200},
+		{"retry after failure", database.StatusFailed, "pending", 200},
+		{"skipping states", database.StatusPending, "deployed", 409},
+		{"leaving rolled back", database.StatusRolledBack, "pending", 409},
+		{"unknown state", database.StatusPending, "shipped", 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			app := fiber.New()
+			app.Post("/deployments/:id/transition", TransitionDeployment)
+			deployment := newDeployment(t, tt.from)
+
+			var body map[string]interface{}
+			resp := send(t, app, "POST", "/deployments/1/transition", `{"status":"`+tt.to+`"}`, nil, &body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %v", resp.StatusCode, tt.status, body)
+			}
+
+			var stored database.Deployment
+			database.DB.First(&stored, deployment.ID)
+			want := tt.from
+			if tt.status == 200 {
+				want = database.DeploymentStatus(tt.to)
+			}
+			if stored.Status != want {
+				t.Fatalf("stored status = %s, want %s", stored.Status, want)
+			}
+		})
+	}
+}
+
+func TestTransitionToDeployedNeedsRequiredItems(t *testing.T) {
+	openTestDB(t)
+	app := fiber.New()
+	app.Post("/deployments/:id/transition", TransitionDeployment)
+
+	deployment := newDeployment(t, database.StatusDeploying,
+		database.ChecklistItem{Title: "Backup taken", Required: true, Position: 1},
+		database.ChecklistItem{Title: "Notify support", Required: false, Position: 2},
+	)
+
+	var refused struct {
+		Error     string                   `json:"error"`
+		OpenItems []database.ChecklistItem `json:"open_items"`
+	}
+	resp := send(t, app, "POST", "/deployments/1/transition", `{"status":"deployed"}`, nil, &refused)
+	if resp.StatusCode != 409 {
+		t.Fatalf("status = %d, want 409", resp.StatusCode)
+	}
+	if len(refused.OpenItems) != 1 || refused.OpenItems[0].Title != "Backup taken" {
+		t.Fatalf("open_items = %+v, want only the required item", refused.OpenItems)
+	}
+
+	// The optional item may stay open
+	database.DB.Model(&database.ChecklistItem{}).Where("title = ?", "Backup taken").Update("checked", true)
+
+	var deployed database.Deployment
+	resp = send(t, app, "POST", "/deployments/1/transition", `{"status":"deployed"}`, nil, &deployed)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status after ticking = %d, want 200", resp.StatusCode)
+	}
+	if deployed.Status != database.StatusDeployed || deployed.DeployStatus != "success" {
+		t.Fatalf("deployment = %s/%s, want deployed/success", deployed.Status, deployed.DeployStatus)
+	}
+	if deployed.Version != deployment.Version+1 {
+		t.Fatalf("version = %d, want %d", deployed.Version, deployment.Version+1)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"chklst-go/internal/database"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Responses for single rows carry an ETag holding the row version. Updates
+// honour If-Match: a stale version fails with 412 before anything is
+// written, and a write that loses a race fails the same way.
+
+// versionETag formats a row version as a strong ETag
+func versionETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// setETag sets the ETag header for a row version
+func setETag(c fiber.Ctx, version uint) {
+	c.Set(fiber.HeaderETag, versionETag(version))
+}
+
+// libraryETag returns a strong ETag for the library. The library is a view
+// over several tables, so the tag is a hash of its content.
+func libraryETag(library database.Library) string {
+	data, _ := json.Marshal(library)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ifMatch reports whether the request's If-Match header accepts etag. A
+// missing header or "*" accepts any current representation.
+func ifMatch(c fiber.Ctx, etag string) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed responds 412 with the current ETag so the client can
+// reload and retry
+func preconditionFailed(c fiber.Ctx, etag string) error {
+	c.Set(fiber.HeaderETag, etag)
+	return c.Status(412).JSON(fiber.Map{
+		"error": "Resource was modified, reload and retry",
+		"etag":  etag,
+	})
+}
+
+// versionConflict maps a SaveVersion error to a response. Lost races are
+// reported as 412 like a stale If-Match.
+func versionConflict(c fiber.Ctx, err error, message string) error {
+	if errors.Is(err, database.ErrVersionConflict) {
+		return c.Status(412).JSON(fiber.Map{
+			"error": "Resource was modified, reload and retry",
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": message,
+	})
+}
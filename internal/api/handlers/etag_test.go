@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"testing"
+
+	"chklst-go/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		ifMatch string
+		status  int
+		etag    string
+	}{
+		{"no header", "PUT", "", 200, `"2"`},
+		{"any version", "PUT", "*", 200, `"2"`},
+		{"current version", "PUT", `"1"`, 200, `"2"`},
+		{"one of several", "PUT", `"7", "1"`, 200, `"2"`},
+		{"stale version", "PUT", `"0"`, 412, `"1"`},
+		{"weak tag", "PUT", `W/"1"`, 412, `"1"`},
+		{"unquoted", "PUT", `1`, 412, `"1"`},
+		{"patch, current version", "PATCH", `"1"`, 200, `"2"`},
+		{"patch, stale version", "PATCH", `"3"`, 412, `"1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			create(t, &database.Project{Name: "billing", Version: 1})
+
+			app := fiber.New()
+			app.Put("/projects/:id", UpdateProject)
+			app.Patch("/projects/:id", PatchProject)
+
+			var header map[string]string
+			if tt.ifMatch != "" {
+				header = map[string]string{"If-Match": tt.ifMatch}
+			}
+			resp := send(t, app, tt.method, "/projects/1", `{"name":"payroll"}`, header, nil)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if etag := resp.Header.Get("ETag"); etag != tt.etag {
+				t.Fatalf("ETag = %s, want %s", etag, tt.etag)
+			}
+
+			var stored database.Project
+			database.DB.First(&stored, 1)
+			var events int64
+			database.DB.Model(&database.AuditEvent{}).Count(&events)
+			if tt.status == 412 && (stored.Name != "billing" || stored.Version != 1 || events != 0) {
+				t.Fatalf("a refused update was written: %+v, %d audit events", stored, events)
+			}
+			if tt.status == 200 && (stored.Name != "payroll" || stored.Version != 2 || events != 1) {
+				t.Fatalf("update not written: %+v, %d audit events", stored, events)
+			}
+		})
+	}
+}
+
+func TestVersionConflictIsPreconditionFailed(t *testing.T) {
+	openTestDB(t)
+	create(t, &database.Project{Name: "billing", Version: 1})
+
+	// Another write bumps the version between the handler's read and its save
+	raced := false
+	err := database.DB.Callback().Update().Before("gorm:update").Register("test:race", func(tx *gorm.DB) {
+		if raced {
+			return
+		}
+		raced = true
+		tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE projects SET version = version + 1 WHERE id = 1")
+	})
+	if err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+
+	app := fiber.New()
+	app.Put("/projects/:id", UpdateProject)
+
+	var body map[string]interface{}
+	resp := send(t, app, "PUT", "/projects/1", `{"name":"payroll"}`, nil, &body)
+	if !raced {
+		t.Fatal("the handler saved without an update")
+	}
+	if resp.StatusCode != 412 {
+		t.Fatalf("status = %d, want 412: %v", resp.StatusCode, body)
+	}
+
+	var stored database.Project
+	database.DB.First(&stored, 1)
+	var events int64
+	database.DB.Model(&database.AuditEvent{}).Count(&events)
+	if stored.Name != "billing" || events != 0 {
+		t.Fatalf("the losing write was saved: %+v, %d audit events", stored, events)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"chklst-go/internal/config"
 	"chklst-go/internal/database"
 	"time"
 
@@ -31,6 +32,6 @@ func HealthCheck(c fiber.Ctx) error {
 		"status":    "healthy",
 		"database":  "connected",
 		"timestamp": time.Now().Format(time.RFC3339),
-		"version":   "1.0.0",
+		"version":   config.Version,
 	})
 }
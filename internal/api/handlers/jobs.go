@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+
+	"chklst-go/internal/utils"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var scheduler *utils.Scheduler
+
+// InitJobHandlers initializes the scheduled job handlers
+func InitJobHandlers(s *utils.Scheduler) {
+	scheduler = s
+}
+
+// ListJobs returns the schedule and last run of every scheduled job
+func ListJobs(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"jobs": scheduler.Jobs(),
+	})
+}
+
+// StartJob resumes a stopped job on its schedule
+func StartJob(c fiber.Ctx) error {
+	return controlJob(c, scheduler.StartJob)
+}
+
+// StopJob stops a job from running on its schedule
+func StopJob(c fiber.Ctx) error {
+	return controlJob(c, scheduler.StopJob)
+}
+
+// RunJob triggers a job immediately
+func RunJob(c fiber.Ctx) error {
+	if err := scheduler.RunNow(c.Params("name")); err != nil {
+		return jobError(c, err)
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"message": "Job started",
+		"name":    c.Params("name"),
+	})
+}
+
+// RescheduleJob replaces a job's cron expression
+func RescheduleJob(c fiber.Ctx) error {
+	var req struct {
+		Schedule string `json:"schedule"`
+	}
+
+	if err := c.Bind().JSON(&req); err != nil || req.Schedule == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "schedule is required",
+		})
+	}
+
+	return controlJob(c, func(name string) error {
+		return scheduler.Reschedule(name, req.Schedule)
+	})
+}
+
+// controlJob applies fn to the job named in the path and returns its status
+func controlJob(c fiber.Ctx, fn func(name string) error) error {
+	name := c.Params("name")
+	if err := fn(name); err != nil {
+		return jobError(c, err)
+	}
+
+	for _, job := range scheduler.Jobs() {
+		if job.Name == name {
+			return c.JSON(job)
+		}
+	}
+
+	return c.Status(404).JSON(fiber.Map{
+		"error": "Job not found",
+	})
+}
+
+// jobError maps scheduler errors to responses
+func jobError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, utils.ErrJobNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	case errors.Is(err, utils.ErrJobRunning):
+		return c.Status(409).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+}
@@ -2,91 +2,38 @@ package handlers
 
 import (
 	"chklst-go/internal/database"
+	"errors"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
 // GetLibrary returns the library presets
 func GetLibrary(c fiber.Ctx) error {
-	var library database.Library
-	
-	if err := database.DB.First(&library).Error; err != nil {
+	library, err := database.LoadLibrary(database.DB)
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to fetch library",
 		})
 	}
 
+	c.Set(fiber.HeaderETag, libraryETag(library))
 	return c.JSON(library)
 }
 
 // AddDeveloper adds a developer to the library
 func AddDeveloper(c fiber.Ctx) error {
-	var req struct {
-		Name string `json:"name"`
-	}
-
-	if err := c.Bind().JSON(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
-	}
-
-	// Add developer if not exists
-	for _, dev := range library.Developers {
-		if dev == req.Name {
-			return c.Status(409).JSON(fiber.Map{
-				"error": "Developer already exists",
-			})
-		}
-	}
-
-	library.Developers = append(library.Developers, req.Name)
-	if err := database.DB.Save(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update library",
-		})
-	}
-
-	return c.Status(201).JSON(library)
+	return addLibraryEntry(c, database.LibraryDevelopers, "Developer already exists")
 }
 
 // RemoveDeveloper removes a developer from the library
 func RemoveDeveloper(c fiber.Ctx) error {
-	name := c.Params("name")
-
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
-	}
-
-	// Remove developer
-	newDevs := []string{}
-	for _, dev := range library.Developers {
-		if dev != name {
-			newDevs = append(newDevs, dev)
-		}
-	}
-
-	library.Developers = newDevs
-	if err := database.DB.Save(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update library",
-		})
-	}
-
-	return c.SendStatus(204)
+	return removeLibraryEntry(c, database.LibraryDevelopers)
 }
 
-// UpdateLibrary updates the entire library (bulk update)
+// UpdateLibrary updates the entire library (bulk update). The library is
+// read, checked against If-Match and written in one transaction.
 func UpdateLibrary(c fiber.Ctx) error {
 	var req database.Library
 	if err := c.Bind().JSON(&req); err != nil {
@@ -95,96 +42,125 @@ func UpdateLibrary(c fiber.Ctx) error {
 		})
 	}
 
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
-	}
+	var before, library database.Library
+	var inUse []database.LibraryUsage
+	errStale := errors.New("stale library")
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if before, err = database.LoadLibrary(tx); err != nil {
+			return err
+		}
+		if !ifMatch(c, libraryETag(before)) {
+			return errStale
+		}
+
+		// Update all fields
+		library = req
 
-	// Update all fields
-	library.Developers = req.Developers
-	library.BuildServers = req.BuildServers
-	library.DeployServers = req.DeployServers
-	library.Environments = req.Environments
+		// Refuse to drop entries that rows still use unless ?force=true
+		if c.Query("force") != "true" {
+			if inUse, err = database.RemovedInUse(tx, before, library); err != nil || len(inUse) > 0 {
+				return err
+			}
+		}
 
-	if err := database.DB.Save(&library).Error; err != nil {
+		if err := database.SaveLibrary(tx, library); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityLibrary, 0, database.AuditUpdate, before, library)
+	})
+	switch {
+	case errors.Is(err, errStale):
+		return preconditionFailed(c, libraryETag(before))
+	case err != nil:
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update library",
 		})
+	case len(inUse) > 0:
+		return c.Status(409).JSON(fiber.Map{
+			"error":  "Removed library entries are still in use",
+			"in_use": inUse,
+		})
 	}
 
+	c.Set(fiber.HeaderETag, libraryETag(library))
 	return c.JSON(library)
 }
 
 // AddBuildServer adds a build server to the library
 func AddBuildServer(c fiber.Ctx) error {
-	var req struct {
-		Name string `json:"name"`
-	}
+	return addLibraryEntry(c, database.LibraryBuildServers, "Build server already exists")
+}
 
-	if err := c.Bind().JSON(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
+// RemoveBuildServer removes a build server from the library
+func RemoveBuildServer(c fiber.Ctx) error {
+	return removeLibraryEntry(c, database.LibraryBuildServers)
+}
 
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
-	}
+// AddDeployServer adds a deploy server to the library
+func AddDeployServer(c fiber.Ctx) error {
+	return addLibraryEntry(c, database.LibraryDeployServers, "Deploy server already exists")
+}
 
-	// Check for duplicate
-	for _, server := range library.BuildServers {
-		if server == req.Name {
-			return c.Status(409).JSON(fiber.Map{
-				"error": "Build server already exists",
-			})
-		}
-	}
+// RemoveDeployServer removes a deploy server from the library
+func RemoveDeployServer(c fiber.Ctx) error {
+	return removeLibraryEntry(c, database.LibraryDeployServers)
+}
 
-	library.BuildServers = append(library.BuildServers, req.Name)
-	if err := database.DB.Save(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update library",
-		})
-	}
+// AddEnvironment adds an environment to the library
+func AddEnvironment(c fiber.Ctx) error {
+	return addLibraryEntry(c, database.LibraryEnvironments, "Environment already exists")
+}
 
-	return c.Status(201).JSON(library)
+// RemoveEnvironment removes an environment from the library
+func RemoveEnvironment(c fiber.Ctx) error {
+	return removeLibraryEntry(c, database.LibraryEnvironments)
 }
 
-// RemoveBuildServer removes a build server from the library
-func RemoveBuildServer(c fiber.Ctx) error {
-	name := c.Params("name")
+// RenameDeveloper renames a developer on every row that uses it
+func RenameDeveloper(c fiber.Ctx) error {
+	return renameLibraryEntry(c, database.LibraryDevelopers)
+}
 
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
-	}
+// MergeDeveloper merges a developer into another
+func MergeDeveloper(c fiber.Ctx) error {
+	return mergeLibraryEntry(c, database.LibraryDevelopers)
+}
 
-	newServers := []string{}
-	for _, server := range library.BuildServers {
-		if server != name {
-			newServers = append(newServers, server)
-		}
-	}
+// RenameBuildServer renames a build server on every row that uses it
+func RenameBuildServer(c fiber.Ctx) error {
+	return renameLibraryEntry(c, database.LibraryBuildServers)
+}
 
-	library.BuildServers = newServers
-	if err := database.DB.Save(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update library",
-		})
-	}
+// MergeBuildServer merges a build server into another server
+func MergeBuildServer(c fiber.Ctx) error {
+	return mergeLibraryEntry(c, database.LibraryBuildServers)
+}
 
-	return c.SendStatus(204)
+// RenameDeployServer renames a deploy server on every row that uses it
+func RenameDeployServer(c fiber.Ctx) error {
+	return renameLibraryEntry(c, database.LibraryDeployServers)
 }
 
-// AddDeployServer adds a deploy server to the library
-func AddDeployServer(c fiber.Ctx) error {
+// MergeDeployServer merges a deploy server into another server
+func MergeDeployServer(c fiber.Ctx) error {
+	return mergeLibraryEntry(c, database.LibraryDeployServers)
+}
+
+// RenameEnvironment renames an environment on every row that uses it
+func RenameEnvironment(c fiber.Ctx) error {
+	return renameLibraryEntry(c, database.LibraryEnvironments)
+}
+
+// MergeEnvironment merges an environment into another
+func MergeEnvironment(c fiber.Ctx) error {
+	return mergeLibraryEntry(c, database.LibraryEnvironments)
+}
+
+// addLibraryEntry adds the "name" in the request body to a library
+// category. Concurrent additions all land.
+func addLibraryEntry(c fiber.Ctx, category string, existsMessage string) error {
 	var req struct {
 		Name string `json:"name"`
 	}
@@ -196,62 +172,83 @@ func AddDeployServer(c fiber.Ctx) error {
 	}
 
 	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
-	}
-
-	for _, server := range library.DeployServers {
-		if server == req.Name {
-			return c.Status(409).JSON(fiber.Map{
-				"error": "Deploy server already exists",
-			})
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := database.LoadLibrary(tx)
+		if err != nil {
+			return err
+		}
+		if err := database.AddLibraryEntry(tx, category, req.Name); err != nil {
+			return err
 		}
+		if library, err = database.LoadLibrary(tx); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityLibrary, 0, database.AuditUpdate, before, library)
+	})
+	if errors.Is(err, database.ErrLibraryEntryExists) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": existsMessage,
+		})
 	}
-
-	library.DeployServers = append(library.DeployServers, req.Name)
-	if err := database.DB.Save(&library).Error; err != nil {
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update library",
 		})
 	}
 
+	c.Set(fiber.HeaderETag, libraryETag(library))
 	return c.Status(201).JSON(library)
 }
 
-// RemoveDeployServer removes a deploy server from the library
-func RemoveDeployServer(c fiber.Ctx) error {
-	name := c.Params("name")
+// libraryEntityTypes maps library categories to audited entity types
+var libraryEntityTypes = map[string]string{
+	database.LibraryDevelopers:    entityDeveloper,
+	database.LibraryBuildServers:  entityServer,
+	database.LibraryDeployServers: entityServer,
+	database.LibraryEnvironments:  entityEnvironment,
+}
 
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
+// renameLibraryEntry renames the :name entry of category to the body's
+// "name" and returns the number of rows changed
+func renameLibraryEntry(c fiber.Ctx, category string) error {
+	var req struct {
+		Name string `json:"name"`
 	}
 
-	newServers := []string{}
-	for _, server := range library.DeployServers {
-		if server != name {
-			newServers = append(newServers, server)
-		}
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
 	}
 
-	library.DeployServers = newServers
-	if err := database.DB.Save(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update library",
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Name is required",
 		})
 	}
 
-	return c.SendStatus(204)
+	var change *database.LibraryChange
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if change, err = database.RenameLibraryEntry(tx, category, c.Params("name"), req.Name); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, libraryEntityTypes[category], change.ID, database.AuditUpdate,
+			fiber.Map{"name": change.From}, fiber.Map{"name": change.To})
+	})
+	if err != nil {
+		return libraryChangeError(c, err, nil)
+	}
+
+	return c.JSON(change)
 }
 
-// AddEnvironment adds an environment to the library
-func AddEnvironment(c fiber.Ctx) error {
+// mergeLibraryEntry merges the :name entry of category into the body's
+// "into" and returns the number of rows changed
+func mergeLibraryEntry(c fiber.Ctx, category string) error {
 	var req struct {
-		Name string `json:"name"`
+		Into string `json:"into"`
 	}
 
 	if err := c.Bind().JSON(&req); err != nil {
@@ -260,55 +257,121 @@ func AddEnvironment(c fiber.Ctx) error {
 		})
 	}
 
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
+	if req.Into == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Merge target is required",
 		})
 	}
 
-	for _, env := range library.Environments {
-		if env == req.Name {
-			return c.Status(409).JSON(fiber.Map{
-				"error": "Environment already exists",
-			})
+	var change *database.LibraryChange
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if change, err = database.MergeLibraryEntries(tx, category, c.Params("name"), req.Into); err != nil {
+			return err
 		}
-	}
-
-	library.Environments = append(library.Environments, req.Name)
-	if err := database.DB.Save(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update library",
+		err = recordAudit(c, tx, libraryEntityTypes[category], change.ID, database.AuditMerge, nil, fiber.Map{
+			"merged":              change.From,
+			"into":                change.To,
+			"rows_changed":        change.Changed,
+			"revoked_permissions": len(change.RevokedPermissions),
 		})
+		if err != nil {
+			return err
+		}
+		return auditRevokedPermissions(c, tx, change)
+	})
+	if err != nil {
+		return libraryChangeError(c, err, nil)
 	}
 
-	return c.Status(201).JSON(library)
+	return c.JSON(change)
 }
 
-// RemoveEnvironment removes an environment from the library
-func RemoveEnvironment(c fiber.Ctx) error {
-	name := c.Params("name")
+// removeLibraryEntry removes the :name entry of category. Entries that rows
+// still use are only removed with ?force=true, which leaves the rows' names
+// as they are, or ?reassign_to=<entry>, which moves the rows to another
+// entry of the category first and returns the number of rows changed.
+func removeLibraryEntry(c fiber.Ctx, category string) error {
+	var change *database.LibraryChange
+	var refs []database.LibraryReference
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := database.LoadLibrary(tx)
+		if err != nil {
+			return err
+		}
+		if change, refs, err = database.RemoveLibraryEntry(tx, category, c.Params("name"), removeOptions(c)); err != nil {
+			return err
+		}
+		after, err := database.LoadLibrary(tx)
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(c, tx, entityLibrary, 0, database.AuditUpdate, before, after); err != nil {
+			return err
+		}
+		return auditRevokedPermissions(c, tx, change)
+	})
+	if err != nil {
+		return libraryChangeError(c, err, refs)
+	}
 
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch library",
-		})
+	if change != nil {
+		return c.JSON(change)
 	}
+	return c.SendStatus(204)
+}
 
-	newEnvs := []string{}
-	for _, env := range library.Environments {
-		if env != name {
-			newEnvs = append(newEnvs, env)
+// auditRevokedPermissions records the grants a merge or reassignment
+// revoked, like RevokePermission does, so they can be reviewed and granted
+// again where appropriate
+func auditRevokedPermissions(c fiber.Ctx, tx *gorm.DB, change *database.LibraryChange) error {
+	if change == nil {
+		return nil
+	}
+	for _, grant := range change.RevokedPermissions {
+		if err := recordAudit(c, tx, entityPermission, grant.ID, database.AuditDelete, grant, nil); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	library.Environments = newEnvs
-	if err := database.DB.Save(&library).Error; err != nil {
+// removeOptions reads ?force=true and ?reassign_to= from the request
+func removeOptions(c fiber.Ctx) database.RemoveOptions {
+	return database.RemoveOptions{
+		Force:      c.Query("force") == "true",
+		ReassignTo: strings.TrimSpace(c.Query("reassign_to")),
+	}
+}
+
+// libraryChangeError maps library rename, merge and removal errors to
+// responses. refs lists the rows that block a removal.
+func libraryChangeError(c fiber.Ctx, err error, refs []database.LibraryReference) error {
+	switch {
+	case errors.Is(err, database.ErrLibraryEntryNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, database.ErrLibraryEntryExists):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Name already exists, merge the entries instead",
+		})
+	case errors.Is(err, database.ErrLibraryEntryInUse):
+		return c.Status(409).JSON(fiber.Map{
+			"error":      "Library entry is still in use, pass force=true or reassign_to",
+			"references": refs,
+		})
+	case errors.Is(err, database.ErrProductionEnvironment):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Production environments cannot be renamed or merged",
+		})
+	case errors.Is(err, database.ErrSameLibraryEntry):
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	default:
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update library",
 		})
 	}
-
-	return c.SendStatus(204)
 }
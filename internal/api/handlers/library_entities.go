@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"chklst-go/internal/database"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Developers, servers and environments are the library entities behind the
+// preset lists. Renaming one rewrites the names stored on the rows that
+// link to it; deleting one unlinks those rows but keeps their names.
+
+// ListDevelopers returns all developers
+func ListDevelopers(c fiber.Ctx) error {
+	var developers []database.Developer
+
+	if err := database.DB.Order("name ASC").Find(&developers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch developers",
+		})
+	}
+
+	return c.JSON(developers)
+}
+
+// CreateDeveloper creates a developer
+func CreateDeveloper(c fiber.Ctx) error {
+	var developer database.Developer
+	if err := c.Bind().JSON(&developer); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	developer.ID = 0
+
+	return createEntity(c, entityDeveloper, "developers", &developer, &developer.ID, &developer.Name, &developer.Version)
+}
+
+// UpdateDeveloper updates a developer, renaming it on linked rows
+func UpdateDeveloper(c fiber.Ctx) error {
+	var developer database.Developer
+	if err := database.DB.First(&developer, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Developer not found",
+		})
+	}
+
+	if etag := versionETag(developer.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := developer
+	if err := c.Bind().JSON(&developer); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	developer.ID, developer.CreatedAt = before.ID, before.CreatedAt
+
+	return updateEntity(c, entityDeveloper, "developers", before, &developer, developer.ID, before.Name, &developer.Name, &developer.Version, before.Version, nil)
+}
+
+// developerPatchFields are the developer fields PATCH may change
+var developerPatchFields = []string{"name", "email"}
+
+// PatchDeveloper applies a JSON merge patch to a developer, renaming it on
+// linked rows
+func PatchDeveloper(c fiber.Ctx) error {
+	var developer database.Developer
+	if err := database.DB.First(&developer, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Developer not found",
+		})
+	}
+
+	if etag := versionETag(developer.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := developer
+	changed, err := applyMergePatch(c.Body(), &developer, developerPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityDeveloper, developer.Version, changed, developer)
+	}
+
+	return updateEntity(c, entityDeveloper, "developers", before, &developer, developer.ID, before.Name, &developer.Name, &developer.Version, before.Version, changed)
+}
+
+// DeleteDeveloper deletes a developer
+func DeleteDeveloper(c fiber.Ctx) error {
+	var developer database.Developer
+	return deleteEntity(c, entityDeveloper, "developers", &developer, &developer.ID)
+}
+
+// ListServers returns all servers. ?role=build|deploy filters by role.
+func ListServers(c fiber.Ctx) error {
+	var servers []database.Server
+
+	query := database.DB.Order("name ASC")
+	switch c.Query("role") {
+	case "":
+	case "build":
+		query = query.Where("build = ?", true)
+	case "deploy":
+		query = query.Where("deploy = ?", true)
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": "role must be build or deploy",
+		})
+	}
+
+	if err := query.Find(&servers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch servers",
+		})
+	}
+
+	return c.JSON(servers)
+}
+
+// CreateServer creates a server
+func CreateServer(c fiber.Ctx) error {
+	var server database.Server
+	if err := c.Bind().JSON(&server); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	server.ID = 0
+
+	return createEntity(c, entityServer, "servers", &server, &server.ID, &server.Name, &server.Version)
+}
+
+// UpdateServer updates a server, renaming it on linked rows
+func UpdateServer(c fiber.Ctx) error {
+	var server database.Server
+	if err := database.DB.First(&server, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Server not found",
+		})
+	}
+
+	if etag := versionETag(server.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := server
+	if err := c.Bind().JSON(&server); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	server.ID, server.CreatedAt = before.ID, before.CreatedAt
+
+	return updateEntity(c, entityServer, "servers", before, &server, server.ID, before.Name, &server.Name, &server.Version, before.Version, nil)
+}
+
+// serverPatchFields are the server fields PATCH may change
+var serverPatchFields = []string{
+	"name", "hostname", "ip_address", "os", "owner", "build", "deploy",
+	"description",
+}
+
+// PatchServer applies a JSON merge patch to a server, renaming it on linked
+// rows
+func PatchServer(c fiber.Ctx) error {
+	var server database.Server
+	if err := database.DB.First(&server, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Server not found",
+		})
+	}
+
+	if etag := versionETag(server.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := server
+	changed, err := applyMergePatch(c.Body(), &server, serverPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityServer, server.Version, changed, server)
+	}
+
+	return updateEntity(c, entityServer, "servers", before, &server, server.ID, before.Name, &server.Name, &server.Version, before.Version, changed)
+}
+
+// DeleteServer deletes a server
+func DeleteServer(c fiber.Ctx) error {
+	var server database.Server
+	return deleteEntity(c, entityServer, "servers", &server, &server.ID)
+}
+
+// ListEnvironments returns all environments in display order
+func ListEnvironments(c fiber.Ctx) error {
+	var environments []database.Environment
+
+	if err := database.DB.Order("position ASC, id ASC").Find(&environments).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch environments",
+		})
+	}
+
+	return c.JSON(environments)
+}
+
+// CreateEnvironment creates an environment. Without a position it is
+// appended after the existing ones.
+func CreateEnvironment(c fiber.Ctx) error {
+	var environment database.Environment
+	if err := c.Bind().JSON(&environment); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	environment.ID = 0
+
+	if environment.Position == 0 {
+		var last int
+		database.DB.Model(&database.Environment{}).Select("COALESCE(MAX(position), 0)").Scan(&last)
+		environment.Position = last + 1
+	}
+
+	return createEntity(c, entityEnvironment, "environments", &environment, &environment.ID, &environment.Name, &environment.Version)
+}
+
+// UpdateEnvironment updates an environment, renaming it on linked rows
+func UpdateEnvironment(c fiber.Ctx) error {
+	var environment database.Environment
+	if err := database.DB.First(&environment, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Environment not found",
+		})
+	}
+
+	if etag := versionETag(environment.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := environment
+	if err := c.Bind().JSON(&environment); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	environment.ID, environment.CreatedAt = before.ID, before.CreatedAt
+
+	return updateEntity(c, entityEnvironment, "environments", before, &environment, environment.ID, before.Name, &environment.Name, &environment.Version, before.Version, nil)
+}
+
+// environmentPatchFields are the environment fields PATCH may change
+var environmentPatchFields = []string{"name", "position", "description"}
+
+// PatchEnvironment applies a JSON merge patch to an environment, renaming it
+// on linked rows
+func PatchEnvironment(c fiber.Ctx) error {
+	var environment database.Environment
+	if err := database.DB.First(&environment, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Environment not found",
+		})
+	}
+
+	if etag := versionETag(environment.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := environment
+	changed, err := applyMergePatch(c.Body(), &environment, environmentPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityEnvironment, environment.Version, changed, environment)
+	}
+
+	return updateEntity(c, entityEnvironment, "environments", before, &environment, environment.ID, before.Name, &environment.Name, &environment.Version, before.Version, changed)
+}
+
+// DeleteEnvironment deletes an environment
+func DeleteEnvironment(c fiber.Ctx) error {
+	var environment database.Environment
+	return deleteEntity(c, entityEnvironment, "environments", &environment, &environment.ID)
+}
+
+// createEntity validates the name of a new library entity and stores it
+func createEntity(c fiber.Ctx, entityType string, table string, entity interface{}, id *uint, name *string, version *uint) error {
+	*name = strings.TrimSpace(*name)
+	if *name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Name is required",
+		})
+	}
+
+	if taken, err := entityNameTaken(table, *name, 0); err != nil || taken {
+		return entityNameError(c, err)
+	}
+
+	*version = 1
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entity).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityType, *id, database.AuditCreate, nil, entity)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create " + entityType,
+		})
+	}
+
+	setETag(c, *version)
+	return c.Status(201).JSON(entity)
+}
+
+// updateEntity saves a library entity at the version it was loaded with and,
+// when its name changed, rewrites the name on the rows that link to it.
+// Patches pass the fields they changed, which are listed in the response.
+func updateEntity(c fiber.Ctx, entityType string, table string, before interface{}, entity interface{}, id uint, oldName string, name *string, version *uint, expected uint, changed []string) error {
+	*name = strings.TrimSpace(*name)
+	if *name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Name is required",
+		})
+	}
+
+	if taken, err := entityNameTaken(table, *name, id); err != nil || taken {
+		return entityNameError(c, err)
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.SaveVersion(tx, entity, version, expected); err != nil {
+			return err
+		}
+		if _, _, err := database.ReplaceReferences(tx, table, id, oldName, id, *name); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityType, id, database.AuditUpdate, before, entity)
+	})
+	if errors.Is(err, database.ErrProductionEnvironment) {
+		return libraryChangeError(c, err, nil)
+	}
+	if err != nil {
+		return versionConflict(c, err, "Failed to update "+entityType)
+	}
+
+	if changed != nil {
+		return patched(c, entityType, *version, changed, entity)
+	}
+	setETag(c, *version)
+	return c.JSON(entity)
+}
+
+// deleteEntity deletes the library entity named by the :id parameter.
+// Entities that rows still use are only deleted with ?force=true, which
+// unlinks the rows, or ?reassign_to=<name>, which merges the entity into
+// another one of its kind and returns the number of rows changed.
+func deleteEntity(c fiber.Ctx, entityType string, table string, entity interface{}, id *uint) error {
+	if err := database.DB.First(entity, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": strings.ToUpper(entityType[:1]) + entityType[1:] + " not found",
+		})
+	}
+
+	var change *database.LibraryChange
+	var refs []database.LibraryReference
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if change, refs, err = database.DeleteLibraryEntity(tx, table, *id, removeOptions(c)); err != nil {
+			return err
+		}
+		if err := recordAudit(c, tx, entityType, *id, database.AuditDelete, entity, nil); err != nil {
+			return err
+		}
+		return auditRevokedPermissions(c, tx, change)
+	})
+	if err != nil {
+		return libraryChangeError(c, err, refs)
+	}
+
+	if change != nil {
+		return c.JSON(change)
+	}
+	return c.SendStatus(204)
+}
+
+// entityNameTaken reports whether an entity in table other than id already
+// uses name
+func entityNameTaken(table string, name string, id uint) (bool, error) {
+	var count int64
+	err := database.DB.Table(table).Where("name = ? AND id <> ?", name, id).Count(&count).Error
+	return count > 0, err
+}
+
+// entityNameError reports a failed or positive name clash check
+func entityNameError(c fiber.Ctx, err error) error {
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to check name",
+		})
+	}
+	return c.Status(409).JSON(fiber.Map{
+		"error": "Name already exists",
+	})
+}
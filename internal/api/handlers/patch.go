@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"chklst-go/internal/validation"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// PATCH endpoints take an RFC 7396 JSON merge patch: members set a field,
+// null resets it, and nested objects are merged. Each resource lists the
+// fields a patch may write; ids, versions, timestamps and other fields the
+// server manages are refused. The response names the fields that changed.
+
+// errPatchNotObject is returned when the patch body is not a JSON object
+var errPatchNotObject = errors.New("patch must be a JSON object")
+
+// protectedFieldsError lists patch members that are not writable
+type protectedFieldsError struct {
+	Fields []string
+}
+
+func (e *protectedFieldsError) Error() string {
+	return "fields are not writable: " + strings.Join(e.Fields, ", ")
+}
+
+// applyMergePatch applies the merge patch in body to target, a pointer to a
+// struct, and returns the JSON names of the fields whose value changed.
+// Members whose value does not fit their field are returned as
+// validation.Errors. Target is only modified when the whole patch applies.
+func applyMergePatch(body []byte, target interface{}, writable []string) ([]string, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, errPatchNotObject
+	}
+
+	var protected []string
+	for name := range patch {
+		if !containsString(writable, name) {
+			protected = append(protected, name)
+		}
+	}
+	if len(protected) > 0 {
+		sort.Strings(protected)
+		return nil, &protectedFieldsError{Fields: protected}
+	}
+
+	value := reflect.ValueOf(target).Elem()
+	fields := jsonFields(value.Type())
+	next := reflect.New(value.Type()).Elem()
+	next.Set(value)
+
+	var changed []string
+	var invalid validation.Errors
+	for name, raw := range patch {
+		field := next.FieldByIndex(fields[name])
+
+		current, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		merged := mergeJSON(current, raw)
+
+		decoded := reflect.New(field.Type())
+		if err := json.Unmarshal(merged, decoded.Interface()); err != nil {
+			invalid = append(invalid, typeError(name, field.Type()))
+			continue
+		}
+		field.Set(decoded.Elem())
+
+		updated, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(current, updated) {
+			changed = append(changed, name)
+		}
+	}
+
+	if len(invalid) > 0 {
+		sort.Slice(invalid, func(i, j int) bool { return invalid[i].Field < invalid[j].Field })
+		return nil, invalid
+	}
+
+	value.Set(next)
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// mergeJSON merges patch into target as RFC 7396 describes
+func mergeJSON(target, patch json.RawMessage) json.RawMessage {
+	var members map[string]json.RawMessage
+	if json.Unmarshal(patch, &members) != nil || members == nil {
+		return patch
+	}
+
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(target, &doc) != nil || doc == nil {
+		doc = map[string]json.RawMessage{}
+	}
+	for name, value := range members {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = mergeJSON(doc[name], value)
+	}
+
+	merged, _ := json.Marshal(doc)
+	return merged
+}
+
+// jsonFields maps the JSON names of a struct's fields to their indexes
+func jsonFields(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		fields[name] = field.Index
+	}
+	return fields
+}
+
+// patchError responds to a patch that could not be applied
+func patchError(c fiber.Ctx, err error) error {
+	var protected *protectedFieldsError
+	var invalid validation.Errors
+	switch {
+	case errors.As(err, &protected):
+		return c.Status(400).JSON(fiber.Map{
+			"error":  "Fields cannot be changed with PATCH",
+			"fields": protected.Fields,
+		})
+	case errors.As(err, &invalid):
+		return c.Status(422).JSON(fiber.Map{
+			"error":  "Validation failed",
+			"fields": invalid,
+		})
+	case errors.Is(err, errPatchNotObject):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Patch must be a JSON object",
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": "Failed to apply patch",
+	})
+}
+
+// patched responds with the patched resource under key and the fields the
+// patch changed
+func patched(c fiber.Ctx, key string, version uint, changed []string, resource interface{}) error {
+	if changed == nil {
+		changed = []string{}
+	}
+	setETag(c, version)
+	return c.JSON(fiber.Map{
+		"changed": changed,
+		key:       resource,
+	})
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"chklst-go/internal/database"
+	"chklst-go/internal/validation"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// patchTarget has a field of each kind the resources use
+type patchTarget struct {
+	ID      uint              `json:"id"`
+	Name    string            `json:"name"`
+	Count   int               `json:"count"`
+	Enabled bool              `json:"enabled"`
+	When    time.Time         `json:"when"`
+	Until   *time.Time        `json:"until"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	secret  string
+}
+
+var patchTargetFields = []string{"name", "count", "enabled", "when", "until", "tags", "labels"}
+
+func TestApplyMergePatch(t *testing.T) {
+	when := time.Date(2025, 12, 9, 16, 23, 43, 0, time.UTC)
+	base := func() patchTarget {
+		return patchTarget{
+			ID:      1,
+			Name:    "api",
+			Count:   3,
+			Enabled: true,
+			When:    when,
+			Until:   &when,
+			Tags:    []string{"a", "b"},
+			Labels:  map[string]string{"team": "core", "tier": "1"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		changed []string
+		want    func(*patchTarget)
+	}{
+		{"sets a field", `{"name":"web"}`, []string{"name"}, func(p *patchTarget) { p.Name = "web" }},
+		{"same value is not a change", `{"name":"api","count":3}`, nil, func(p *patchTarget) {}},
+		{"null resets a field", `{"name":null,"until":null}`, []string{"name", "until"}, func(p *patchTarget) { p.Name, p.Until = "", nil }},
+		{"false is a value", `{"enabled":false}`, []string{"enabled"}, func(p *patchTarget) { p.Enabled = false }},
+		{"timestamp", `{"when":"2026-01-02T03:04:05Z"}`, []string{"when"}, func(p *patchTarget) {
+			p.When = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		}},
+		{"arrays are replaced whole", `{"tags":["c"]}`, []string{"tags"}, func(p *patchTarget) { p.Tags = []string{"c"} }},
+		{"objects are merged", `{"labels":{"tier":null,"region":"eu"}}`, []string{"labels"}, func(p *patchTarget) {
+			p.Labels = map[string]string{"team": "core", "region": "eu"}
+		}},
+		{"empty patch", `{}`, nil, func(p *patchTarget) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := base()
+			changed, err := applyMergePatch([]byte(tt.patch), &target, patchTargetFields)
+			if err != nil {
+				t.Fatalf("applyMergePatch: %v", err)
+			}
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Fatalf("changed = %v, want %v", changed, tt.changed)
+			}
+
+			want := base()
+			tt.want(&want)
+			if !reflect.DeepEqual(target, want) {
+				t.Fatalf("target = %+v, want %+v", target, want)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatchRefusals(t *testing.T) {
+	tests := []struct {
+		name      string
+		patch     string
+		protected []string
+		invalid   validation.Errors
+		notObject bool
+	}{
+		{"protected fields, sorted", `{"secret":"x","id":2,"name":"web"}`, []string{"id", "secret"}, nil, false},
+		{"unknown field", `{"colour":"red"}`, []string{"colour"}, nil, false},
+		{"wrong types", `{"when":"yesterday","count":"3","name":"web"}`, nil, validation.Errors{
+			{Field: "count", Rule: "type", Message: "must be a whole number"},
+			{Field: "when", Rule: "type", Message: "must be an RFC 3339 timestamp such as 2025-12-09T16:23:43Z"},
+		}, false},
+		{"wrong type in a pointer field", `{"until":5}`, nil, validation.Errors{
+			{Field: "until", Rule: "type", Message: "must be an RFC 3339 timestamp such as 2025-12-09T16:23:43Z"},
+		}, false},
+		{"string for a bool", `{"enabled":"yes"}`, nil, validation.Errors{
+			{Field: "enabled", Rule: "type", Message: "must be true or false"},
+		}, false},
+		{"array", `[]`, nil, nil, true},
+		{"null", `null`, nil, nil, true},
+		{"scalar", `5`, nil, nil, true},
+		{"not JSON", `{`, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := patchTarget{ID: 1, Name: "api", Count: 3}
+			before := target
+
+			changed, err := applyMergePatch([]byte(tt.patch), &target, patchTargetFields)
+			if err == nil {
+				t.Fatalf("applyMergePatch succeeded with %v", changed)
+			}
+			if !reflect.DeepEqual(target, before) {
+				t.Fatalf("a refused patch modified the target: %+v", target)
+			}
+
+			var protected *protectedFieldsError
+			var invalid validation.Errors
+			switch {
+			case tt.protected != nil:
+				if !errors.As(err, &protected) || !reflect.DeepEqual(protected.Fields, tt.protected) {
+					t.Fatalf("err = %v, want protected fields %v", err, tt.protected)
+				}
+			case tt.invalid != nil:
+				if !errors.As(err, &invalid) || !reflect.DeepEqual(invalid, tt.invalid) {
+					t.Fatalf("err = %#v, want %#v", err, tt.invalid)
+				}
+			case tt.notObject:
+				if !errors.Is(err, errPatchNotObject) {
+					t.Fatalf("err = %v, want errPatchNotObject", err)
+				}
+			}
+		})
+	}
+}
+
+// TestPatchFieldWhitelists checks that every writable field exists on its
+// model and that fields the server manages are never writable
+func TestPatchFieldWhitelists(t *testing.T) {
+	serverManaged := []string{"id", "external_id", "version", "created_at", "updated_at"}
+
+	tests := []struct {
+		resource string
+		fields   []string
+		model    interface{}
+		refused  []string
+	}{
+		{"project", projectPatchFields, database.Project{}, nil},
+		{"component", componentPatchFields, database.Component{}, []string{"project_id"}},
+		{"deployment", deploymentPatchFields, database.Deployment{}, []string{"status", "build_status", "deploy_status", "deployed_by", "checklist"}},
+		{"checklist template", checklistTemplatePatchFields, database.ChecklistTemplate{}, nil},
+		{"settings", settingsPatchFields, database.Settings{}, nil},
+		{"user", userPatchFields, database.User{}, []string{"username", "password_hash", "password_changed_at", "last_login_at"}},
+		{"developer", developerPatchFields, database.Developer{}, nil},
+		{"server", serverPatchFields, database.Server{}, nil},
+		{"environment", environmentPatchFields, database.Environment{}, nil},
+	}
+
+	for _, tt := range tests {
+		fields := jsonFields(reflect.TypeOf(tt.model))
+		for _, name := range tt.fields {
+			if _, ok := fields[name]; !ok {
+				t.Errorf("%s: writable field %q is not a JSON field of %T", tt.resource, name, tt.model)
+			}
+		}
+		for _, name := range append(serverManaged, tt.refused...) {
+			if containsString(tt.fields, name) {
+				t.Errorf("%s: %q must not be writable", tt.resource, name)
+			}
+		}
+	}
+}
+
+func TestPatchAndPutReportTypeErrorsAlike(t *testing.T) {
+	openTestDB(t)
+	newDeployment(t, database.StatusPending)
+
+	app := fiber.New()
+	app.Put("/deployments/:id", UpdateDeployment)
+	app.Patch("/deployments/:id", PatchDeployment)
+
+	var patchBody, putBody map[string]interface{}
+	patchResp := send(t, app, "PATCH", "/deployments/1", `{"timestamp":"yesterday"}`, nil, &patchBody)
+	putResp := send(t, app, "PUT", "/deployments/1", `{"timestamp":"yesterday"}`, nil, &putBody)
+
+	if patchResp.StatusCode != 422 || putResp.StatusCode != 422 {
+		t.Fatalf("PATCH = %d, PUT = %d, want 422 for both", patchResp.StatusCode, putResp.StatusCode)
+	}
+	if !reflect.DeepEqual(patchBody, putBody) {
+		t.Fatalf("PATCH and PUT differ:\nPATCH %v\nPUT   %v", patchBody, putBody)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
 // ListProjects returns all projects
@@ -37,6 +38,7 @@ func GetProject(c fiber.Ctx) error {
 		})
 	}
 
+	setETag(c, project.Version)
 	return c.JSON(project)
 }
 
@@ -50,19 +52,24 @@ func CreateProject(c fiber.Ctx) error {
 		})
 	}
 
-	// Validate required fields
-	if project.Name == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Project name is required",
-		})
+	if ok, err := validate(c, &project); !ok {
+		return err
 	}
 
-	if err := database.DB.Create(&project).Error; err != nil {
+	project.Version = 1
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityProject, project.ID, database.AuditCreate, nil, project)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create project",
 		})
 	}
 
+	setETag(c, project.Version)
 	return c.Status(201).JSON(project)
 }
 
@@ -82,22 +89,79 @@ func UpdateProject(c fiber.Ctx) error {
 		})
 	}
 
-	if err := c.Bind().JSON(&project); err != nil {
+	if etag := versionETag(project.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := project
+
+	if ok, err := bindJSON(c, &project); !ok {
+		return err
+	}
+	project.ID = before.ID
+	project.ExternalID = before.ExternalID // Stable across instances, never reassigned
+
+	if ok, err := validateChanges(c, &before, &project); !ok {
+		return err
+	}
+
+	if err := saveAudited(c, entityProject, project.ID, before, &project, &project.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update project")
+	}
+
+	setETag(c, project.Version)
+	return c.JSON(project)
+}
+
+// projectPatchFields are the project fields PATCH may change
+var projectPatchFields = []string{
+	"name", "build_server", "deploy_server", "database_name",
+	"environment", "backup_location", "description",
+}
+
+// PatchProject applies a JSON merge patch to a project
+func PatchProject(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
+			"error": "Invalid project ID",
 		})
 	}
 
-	if err := database.DB.Save(&project).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update project",
+	var project database.Project
+	if err := database.DB.First(&project, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Project not found",
 		})
 	}
 
-	return c.JSON(project)
+	if etag := versionETag(project.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := project
+
+	changed, err := applyMergePatch(c.Body(), &project, projectPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityProject, project.Version, changed, project)
+	}
+
+	if ok, err := validate(c, &project, changed...); !ok {
+		return err
+	}
+
+	if err := saveAudited(c, entityProject, project.ID, before, &project, &project.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update project")
+	}
+
+	return patched(c, entityProject, project.Version, changed, project)
 }
 
-// DeleteProject deletes a project
+// DeleteProject deletes a project with its components, deployments and
+// checklist templates
 func DeleteProject(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -106,7 +170,16 @@ func DeleteProject(c fiber.Ctx) error {
 		})
 	}
 
-	if err := database.DB.Delete(&database.Project{}, id).Error; err != nil {
+	var project database.Project
+	found := database.DB.First(&project, id).Error == nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.DeleteProject(tx, uint(id)); err != nil || !found {
+			return err
+		}
+		return recordAudit(c, tx, entityProject, project.ID, database.AuditDelete, project, nil)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete project",
 		})
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"chklst-go/internal/database"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Search runs a ranked full-text search across deployments, projects and components
+func Search(c fiber.Ctx) error {
+	if !database.SearchEnabled {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Full-text search is not available in this build",
+		})
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Query parameter q is required",
+		})
+	}
+
+	var entityTypes []string
+	if types := c.Query("type"); types != "" {
+		for _, entityType := range strings.Split(types, ",") {
+			entityType = strings.TrimSpace(entityType)
+			if !containsString(database.SearchEntityTypes, entityType) {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "type must be a comma-separated list of deployment, project, component",
+				})
+			}
+			entityTypes = append(entityTypes, entityType)
+		}
+	}
+
+	limit, err := queryInt(c, "limit", 20)
+	if err != nil || limit < 1 || limit > 100 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "limit must be between 1 and 100",
+		})
+	}
+
+	hits, err := database.Search(q, entityTypes, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to search",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"query": q,
+		"hits":  hits,
+	})
+}
@@ -4,27 +4,19 @@ import (
 	"chklst-go/internal/database"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
 // GetSettings returns the application settings
 func GetSettings(c fiber.Ctx) error {
-	var settings database.Settings
-
-	// Get or create settings (singleton pattern)
-	if err := database.DB.First(&settings).Error; err != nil {
-		// Create default settings if not exists
-		settings = database.Settings{
-			DefaultDeployedBy:  "Kannan",
-			ExcelExportPath:    "/reports",
-			AutoClearAfterSave: false,
-		}
-		if err := database.DB.Create(&settings).Error; err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to create default settings",
-			})
-		}
+	settings, err := loadSettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create default settings",
+		})
 	}
 
+	setETag(c, settings.Version)
 	return c.JSON(settings)
 }
 
@@ -42,24 +34,88 @@ func UpdateSettings(c fiber.Ctx) error {
 		// Create if not exists
 		settings = req
 		settings.ID = 1
-		if err := database.DB.Create(&settings).Error; err != nil {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&settings).Error; err != nil {
+				return err
+			}
+			return recordAudit(c, tx, entitySettings, settings.ID, database.AuditCreate, nil, settings)
+		})
+		if err != nil {
 			return c.Status(500).JSON(fiber.Map{
 				"error": "Failed to create settings",
 			})
 		}
+		setETag(c, settings.Version)
 		return c.JSON(settings)
 	}
 
+	if etag := versionETag(settings.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := settings
+
 	// Update existing settings
 	settings.DefaultDeployedBy = req.DefaultDeployedBy
 	settings.ExcelExportPath = req.ExcelExportPath
 	settings.AutoClearAfterSave = req.AutoClearAfterSave
 
-	if err := database.DB.Save(&settings).Error; err != nil {
+	if err := saveAudited(c, entitySettings, settings.ID, before, &settings, &settings.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update settings")
+	}
+
+	setETag(c, settings.Version)
+	return c.JSON(settings)
+}
+
+// settingsPatchFields are the settings PATCH may change
+var settingsPatchFields = []string{
+	"default_deployed_by", "excel_export_path", "auto_clear_after_save",
+}
+
+// PatchSettings applies a JSON merge patch to the application settings
+func PatchSettings(c fiber.Ctx) error {
+	settings, err := loadSettings()
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to update settings",
+			"error": "Failed to create default settings",
 		})
 	}
 
-	return c.JSON(settings)
+	if etag := versionETag(settings.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := settings
+
+	changed, err := applyMergePatch(c.Body(), &settings, settingsPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entitySettings, settings.Version, changed, settings)
+	}
+
+	if err := saveAudited(c, entitySettings, settings.ID, before, &settings, &settings.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update settings")
+	}
+
+	return patched(c, entitySettings, settings.Version, changed, settings)
+}
+
+// loadSettings returns the settings row, creating the defaults on first use
+// (singleton pattern)
+func loadSettings() (database.Settings, error) {
+	var settings database.Settings
+	if err := database.DB.First(&settings).Error; err == nil {
+		return settings, nil
+	}
+
+	settings = database.Settings{
+		DefaultDeployedBy:  "Kannan",
+		ExcelExportPath:    "/reports",
+		AutoClearAfterSave: false,
+	}
+	err := database.DB.Create(&settings).Error
+	return settings, err
 }
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/auth"
+	"chklst-go/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// ListUsers returns all users
+func ListUsers(c fiber.Ctx) error {
+	var users []database.User
+
+	if err := database.DB.Order("username ASC").Find(&users).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch users",
+		})
+	}
+
+	return c.JSON(users)
+}
+
+// CreateUser creates a new local user
+func CreateUser(c fiber.Ctx) error {
+	var req struct {
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+		Password    string `json:"password"`
+	}
+
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Username == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Username is required",
+		})
+	}
+
+	if len(req.Password) < minPasswordLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Password must be at least 8 characters",
+		})
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+
+	user := database.User{
+		Username:     req.Username,
+		DisplayName:  req.DisplayName,
+		PasswordHash: hash,
+	}
+
+	var existing int64
+	database.DB.Model(&database.User{}).Where("username = ?", req.Username).Count(&existing)
+	if existing > 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Username already exists",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityUser, user.ID, database.AuditCreate, nil, user)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+
+	return c.Status(201).JSON(user)
+}
+
+// UpdateUser updates a user's display name, password or disabled flag
+func UpdateUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if etag := versionETag(user.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	var req struct {
+		DisplayName *string `json:"display_name"`
+		Password    *string `json:"password"`
+		Disabled    *bool   `json:"disabled"`
+	}
+
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	before := user
+
+	if req.DisplayName != nil {
+		user.DisplayName = *req.DisplayName
+	}
+
+	if req.Disabled != nil {
+		if *req.Disabled && middleware.GetUser(c).ID == user.ID {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "You cannot disable your own account",
+			})
+		}
+		user.Disabled = *req.Disabled
+	}
+
+	if req.Password != nil {
+		if len(*req.Password) < minPasswordLength {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Password must be at least 8 characters",
+			})
+		}
+		hash, err := auth.HashPassword(*req.Password)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update user",
+			})
+		}
+		now := time.Now()
+		user.PasswordHash = hash
+		user.PasswordChangedAt = &now
+	}
+
+	if err := saveAudited(c, entityUser, user.ID, before, &user, &user.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update user")
+	}
+
+	setETag(c, user.Version)
+	return c.JSON(user)
+}
+
+// userPatchFields are the user fields PATCH may change. Passwords are set
+// through PUT.
+var userPatchFields = []string{"display_name", "disabled"}
+
+// PatchUser applies a JSON merge patch to a user
+func PatchUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if etag := versionETag(user.Version); !ifMatch(c, etag) {
+		return preconditionFailed(c, etag)
+	}
+
+	before := user
+
+	changed, err := applyMergePatch(c.Body(), &user, userPatchFields)
+	if err != nil {
+		return patchError(c, err)
+	}
+	if len(changed) == 0 {
+		return patched(c, entityUser, user.Version, changed, user)
+	}
+
+	if user.Disabled && middleware.GetUser(c).ID == user.ID {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "You cannot disable your own account",
+		})
+	}
+
+	if err := saveAudited(c, entityUser, user.ID, before, &user, &user.Version, before.Version); err != nil {
+		return versionConflict(c, err, "Failed to update user")
+	}
+
+	return patched(c, entityUser, user.Version, changed, user)
+}
+
+// DeleteUser deletes a user and their permission grants
+func DeleteUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if middleware.GetUser(c).ID == uint(id) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "You cannot delete your own account",
+		})
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&database.Permission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityUser, user.ID, database.AuditDelete, user, nil)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete user",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// ListUserPermissions returns the role grants of a user
+func ListUserPermissions(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var grants []database.Permission
+	if err := database.DB.Where("user_id = ?", id).Find(&grants).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch permissions",
+		})
+	}
+
+	return c.JSON(grants)
+}
+
+// GrantPermission grants a role to a user, optionally scoped to a project and environment
+func GrantPermission(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var grant database.Permission
+	if err := c.Bind().JSON(&grant); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if !auth.Role(grant.Role).IsValid() {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid role",
+			"roles": auth.Roles(),
+		})
+	}
+
+	if grant.ProjectID != nil {
+		if err := database.DB.First(&database.Project{}, *grant.ProjectID).Error; err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Project not found",
+			})
+		}
+	}
+
+	if grant.Environment != "" {
+		var count int64
+		if err := database.DB.Model(&database.Environment{}).Where("name = ?", grant.Environment).Count(&count).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to fetch environments",
+			})
+		}
+		if count == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Environment not in library",
+			})
+		}
+	}
+
+	grant.ID = 0
+	grant.UserID = user.ID
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&grant).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityPermission, grant.ID, database.AuditCreate, nil, grant)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to grant permission",
+		})
+	}
+
+	return c.Status(201).JSON(grant)
+}
+
+// RevokePermission removes a role grant from a user
+func RevokePermission(c fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	permissionID, err := strconv.Atoi(c.Params("permissionId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid permission ID",
+		})
+	}
+
+	var grant database.Permission
+	if err := database.DB.Where("user_id = ?", userID).First(&grant, permissionID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Permission not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&grant).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, entityPermission, grant.ID, database.AuditDelete, grant, nil)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke permission",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"chklst-go/internal/database"
+	"chklst-go/internal/validation"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// validate checks v against its validate tags, limited to the given JSON
+// fields if any. When v is invalid it responds with 422 and the field
+// errors, reports false and returns the result of responding.
+func validate(c fiber.Ctx, v interface{}, fields ...string) (bool, error) {
+	errs, err := validation.Validate(database.DB, v, fields...)
+	if err != nil {
+		return false, c.Status(500).JSON(fiber.Map{
+			"error": "Failed to validate request",
+		})
+	}
+	if len(errs) > 0 {
+		return false, c.Status(422).JSON(fiber.Map{
+			"error":  "Validation failed",
+			"fields": errs,
+		})
+	}
+	return true, nil
+}
+
+// validateChanges validates only the fields of after, a full replacement of
+// before, whose value changed, so rows stored before a rule existed can still
+// be edited. Both are pointers to structs of the same type.
+func validateChanges(c fiber.Ctx, before interface{}, after interface{}) (bool, error) {
+	changed := changedFields(before, after)
+	if len(changed) == 0 {
+		return true, nil
+	}
+	return validate(c, after, changed...)
+}
+
+// changedFields returns the JSON names of the fields whose value differs
+// between two structs of the same type
+func changedFields(before interface{}, after interface{}) []string {
+	old, updated := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+
+	var changed []string
+	for name, index := range jsonFields(old.Type()) {
+		was, _ := json.Marshal(old.FieldByIndex(index).Interface())
+		is, _ := json.Marshal(updated.FieldByIndex(index).Interface())
+		if !bytes.Equal(was, is) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// bindJSON decodes the request body into v like c.Bind().JSON. Values that
+// do not fit their field are reported like failed validation, with 422 and
+// the field errors, so PUT and PATCH fail the same way. It reports false
+// and returns the result of responding when the body cannot be decoded.
+func bindJSON(c fiber.Ctx, v interface{}) (bool, error) {
+	if err := c.Bind().JSON(v); err == nil {
+		return true, nil
+	}
+	if errs := decodeErrors(c.Body(), v); len(errs) > 0 {
+		return false, c.Status(422).JSON(fiber.Map{
+			"error":  "Validation failed",
+			"fields": errs,
+		})
+	}
+	return false, c.Status(400).JSON(fiber.Map{
+		"error": "Invalid request body",
+	})
+}
+
+// decodeErrors returns the members of a JSON object body whose value does
+// not decode into the field of v, a pointer to a struct, with the same name
+func decodeErrors(body []byte, v interface{}) validation.Errors {
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) != nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(v).Elem()
+	fields := jsonFields(t)
+
+	var errs validation.Errors
+	for name, raw := range members {
+		index, ok := fields[name]
+		if !ok {
+			continue
+		}
+		field := t.FieldByIndex(index)
+		if json.Unmarshal(raw, reflect.New(field.Type).Interface()) != nil {
+			errs = append(errs, typeError(name, field.Type))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// typeError describes a JSON value that does not fit a field of type t
+func typeError(name string, t reflect.Type) validation.FieldError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	message := "has an invalid value"
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		message = "must be an RFC 3339 timestamp such as 2025-12-09T16:23:43Z"
+	case t.Kind() == reflect.String:
+		message = "must be a string"
+	case t.Kind() == reflect.Bool:
+		message = "must be true or false"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		message = "must be a whole number"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		message = "must be a number"
+	}
+	return validation.FieldError{Field: name, Rule: "type", Message: message}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"chklst-go/internal/auth"
+	"chklst-go/internal/database"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Auth middleware requires a valid bearer token and stores the caller in context
+func Auth(tokens *auth.TokenIssuer) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		header := c.Get("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+
+		claims, err := tokens.Verify(token)
+		if err != nil {
+			message := "Invalid token"
+			if errors.Is(err, auth.ErrExpiredToken) {
+				message = "Token expired"
+			}
+			return c.Status(401).JSON(fiber.Map{
+				"error": message,
+			})
+		}
+
+		var user database.User
+		if err := database.DB.First(&user, claims.UserID).Error; err != nil || user.Disabled {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "User not found or disabled",
+			})
+		}
+
+		// Changing the password signs out every session started before
+		if user.PasswordChangedAt != nil && claims.IssuedAt < user.PasswordChangedAt.Unix() {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Token revoked by a password change",
+			})
+		}
+
+		// Store in context
+		c.Locals("user", &user)
+
+		return c.Next()
+	}
+}
+
+// GetUser retrieves the authenticated user from context
+func GetUser(c fiber.Ctx) *database.User {
+	if user, ok := c.Locals("user").(*database.User); ok {
+		return user
+	}
+	return nil
+}
@@ -5,13 +5,22 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/cors"
 )
 
-// CORS returns a configured CORS middleware
-func CORS() fiber.Handler {
+// CORS returns a configured CORS middleware.
+// Credentials are only allowed for an explicit origin list, never for "*".
+func CORS(allowOrigins []string) fiber.Handler {
+	allowCredentials := true
+	for _, origin := range allowOrigins {
+		if origin == "*" {
+			allowCredentials = false
+			break
+		}
+	}
+
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"}, // Allow all origins for development
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"},
-		AllowCredentials: true,
-		ExposeHeaders:    []string{"X-Request-ID"},
+		AllowOrigins:     allowOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID", "If-Match"},
+		AllowCredentials: allowCredentials,
+		ExposeHeaders:    []string{"X-Request-ID", "ETag", "X-Total-Count", "X-Page", "X-Page-Size"},
 	})
 }
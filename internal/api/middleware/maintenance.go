@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RequestGate pauses request handling while maintenance work, such as a
+// database restore, swaps the connection out from under the handlers
+type RequestGate struct {
+	mu sync.RWMutex
+}
+
+// NewRequestGate creates an open request gate
+func NewRequestGate() *RequestGate {
+	return &RequestGate{}
+}
+
+// Handler holds the gate for the lifetime of each request. Requests that
+// arrive during maintenance wait until it has finished.
+func (g *RequestGate) Handler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+
+		return c.Next()
+	}
+}
+
+// Exclusive waits for every other in-flight request to finish, runs fn while
+// new requests are held back, then reopens the gate. It must be called from
+// a handler behind Handler.
+func (g *RequestGate) Exclusive(fn func() error) error {
+	g.mu.RUnlock()
+	g.mu.Lock()
+	defer func() {
+		g.mu.Unlock()
+		g.mu.RLock()
+	}()
+
+	return fn()
+}
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"chklst-go/internal/auth"
+	"chklst-go/internal/database"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Scope identifies the project and environment a request acts on.
+// A nil ProjectID or empty Environment means the request is not tied to one.
+type Scope struct {
+	ProjectID   *uint
+	Environment string
+}
+
+// ScopeResolver extracts the scopes a request acts on
+type ScopeResolver func(c fiber.Ctx) ([]Scope, error)
+
+// Guard enforces role-based permissions backed by the permissions table
+type Guard struct {
+	productionEnvironments map[string]bool
+}
+
+// NewGuard creates a guard; deployments to productionEnvironments
+// additionally require the deployments.production permission
+func NewGuard(productionEnvironments []string) *Guard {
+	envs := make(map[string]bool, len(productionEnvironments))
+	for _, env := range productionEnvironments {
+		envs[env] = true
+	}
+
+	return &Guard{
+		productionEnvironments: envs,
+	}
+}
+
+// RequireAny allows the request if any grant of the caller carries perm,
+// whatever its scope
+func (g *Guard) RequireAny(perm auth.Permission) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		grants, err := loadGrants(c)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to load permissions",
+			})
+		}
+
+		for _, grant := range grants {
+			if auth.Role(grant.Role).Has(perm) {
+				return c.Next()
+			}
+		}
+
+		return forbidden(c, perm, Scope{})
+	}
+}
+
+// Require allows the request only if an unscoped grant of the caller carries perm
+func (g *Guard) Require(perm auth.Permission) fiber.Handler {
+	return g.RequireScoped(perm, func(c fiber.Ctx) ([]Scope, error) {
+		return []Scope{{}}, nil
+	})
+}
+
+// RequireScoped allows the request if every scope returned by resolve is
+// covered by a grant carrying perm
+func (g *Guard) RequireScoped(perm auth.Permission, resolve ScopeResolver) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		scopes, err := resolve(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		grants, err := loadGrants(c)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to load permissions",
+			})
+		}
+
+		for _, scope := range scopes {
+			if !covers(grants, perm, scope) {
+				return forbidden(c, perm, scope)
+			}
+
+			// Production deployments need the extra production permission
+			if perm == auth.PermRecordDeployment && g.productionEnvironments[scope.Environment] &&
+				!covers(grants, auth.PermDeployProduction, scope) {
+				return forbidden(c, auth.PermDeployProduction, scope)
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// covers reports whether any grant carrying perm applies to scope
+func covers(grants []database.Permission, perm auth.Permission, scope Scope) bool {
+	for _, grant := range grants {
+		if !auth.Role(grant.Role).Has(perm) {
+			continue
+		}
+		if grant.ProjectID != nil && (scope.ProjectID == nil || *grant.ProjectID != *scope.ProjectID) {
+			continue
+		}
+		if grant.Environment != "" && grant.Environment != scope.Environment {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// forbidden writes a 403 naming the missing permission
+func forbidden(c fiber.Ctx, perm auth.Permission, scope Scope) error {
+	body := fiber.Map{
+		"error":      fmt.Sprintf("Missing permission: %s", perm),
+		"permission": perm,
+	}
+	if scope.ProjectID != nil {
+		body["project_id"] = *scope.ProjectID
+	}
+	if scope.Environment != "" {
+		body["environment"] = scope.Environment
+	}
+
+	return c.Status(403).JSON(body)
+}
+
+// loadGrants returns the caller's grants, cached for the request
+func loadGrants(c fiber.Ctx) ([]database.Permission, error) {
+	if grants, ok := c.Locals("permissions").([]database.Permission); ok {
+		return grants, nil
+	}
+
+	user := GetUser(c)
+	if user == nil {
+		return nil, nil
+	}
+
+	var grants []database.Permission
+	if err := database.DB.Where("user_id = ?", user.ID).Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	c.Locals("permissions", grants)
+	return grants, nil
+}
+
+// ProjectParam resolves the scope from a project ID route parameter
+func ProjectParam(name string) ScopeResolver {
+	return func(c fiber.Ctx) ([]Scope, error) {
+		id, err := strconv.Atoi(c.Params(name))
+		if err != nil {
+			return nil, errors.New("Invalid project ID")
+		}
+		projectID := uint(id)
+		return []Scope{{ProjectID: &projectID}}, nil
+	}
+}
+
+// deploymentScopeBody holds the scope-relevant fields of a deployment body
+type deploymentScopeBody struct {
+	ProjectID   *uint   `json:"project_id"`
+	Environment *string `json:"environment"`
+}
+
+// DeploymentBody resolves the scope from a deployment request body
+func DeploymentBody() ScopeResolver {
+	return func(c fiber.Ctx) ([]Scope, error) {
+		var body deploymentScopeBody
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return nil, errors.New("Invalid request body")
+		}
+
+		scope := Scope{ProjectID: body.ProjectID}
+		if body.Environment != nil {
+			scope.Environment = *body.Environment
+		}
+		return []Scope{scope}, nil
+	}
+}
+
+// DeploymentParam resolves the scope from the stored deployment, and from the
+// request body when it moves the deployment to another project or environment
+func DeploymentParam(name string) ScopeResolver {
+	return func(c fiber.Ctx) ([]Scope, error) {
+		id, err := strconv.Atoi(c.Params(name))
+		if err != nil {
+			return nil, errors.New("Invalid deployment ID")
+		}
+
+		var deployment database.Deployment
+		if err := database.DB.Select("id", "project_id", "environment").First(&deployment, id).Error; err != nil {
+			// Unknown deployments require an unscoped grant; the handler reports 404
+			return []Scope{{}}, nil
+		}
+
+		current := Scope{ProjectID: &deployment.ProjectID, Environment: deployment.Environment}
+		scopes := []Scope{current}
+
+		var body deploymentScopeBody
+		if len(c.Body()) > 0 && json.Unmarshal(c.Body(), &body) == nil &&
+			(body.ProjectID != nil || body.Environment != nil) {
+			target := current
+			if body.ProjectID != nil {
+				target.ProjectID = body.ProjectID
+			}
+			if body.Environment != nil {
+				target.Environment = *body.Environment
+			}
+			scopes = append(scopes, target)
+		}
+
+		return scopes, nil
+	}
+}
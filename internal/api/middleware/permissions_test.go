@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chklst-go/internal/auth"
+	"chklst-go/internal/database"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB points database.DB at a migrated database in a temporary
+// directory
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDatabase(filepath.Join(t.TempDir(), "chklst.db")); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	database.DB.Logger = logger.Discard
+	t.Cleanup(func() { database.CloseDatabase() })
+
+	if _, err := database.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+}
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestCovers(t *testing.T) {
+	global := database.Permission{Role: string(auth.RoleReleaseManager)}
+	project1 := database.Permission{Role: string(auth.RoleReleaseManager), ProjectID: uintPtr(1)}
+	staging := database.Permission{Role: string(auth.RoleDeveloper), Environment: "staging"}
+	project1Staging := database.Permission{Role: string(auth.RoleDeveloper), ProjectID: uintPtr(1), Environment: "staging"}
+
+	tests := []struct {
+		name   string
+		grants []database.Permission
+		perm   auth.Permission
+		scope  Scope
+		want   bool
+	}{
+		{"no grants", nil, auth.PermRead, Scope{}, false},
+		{"global grant, unscoped request", []database.Permission{global}, auth.PermManageProjects, Scope{}, true},
+		{"global grant, any project", []database.Permission{global}, auth.PermManageProjects, Scope{ProjectID: uintPtr(7)}, true},
+		{"role lacks permission", []database.Permission{global}, auth.PermManageUsers, Scope{}, false},
+		{"project grant, its project", []database.Permission{project1}, auth.PermManageProjects, Scope{ProjectID: uintPtr(1)}, true},
+		{"project grant, other project", []database.Permission{project1}, auth.PermManageProjects, Scope{ProjectID: uintPtr(2)}, false},
+		{"project grant, unscoped request", []database.Permission{project1}, auth.PermManageProjects, Scope{}, false},
+		{"environment grant, its environment", []database.Permission{staging}, auth.PermRecordDeployment, Scope{ProjectID: uintPtr(3), Environment: "staging"}, true},
+		{"environment grant, other environment", []database.Permission{staging}, auth.PermRecordDeployment, Scope{Environment: "production"}, false},
+		{"environment grant, no environment", []database.Permission{staging}, auth.PermRecordDeployment, Scope{ProjectID: uintPtr(3)}, false},
+		{"project and environment grant, both match", []database.Permission{project1Staging}, auth.PermRecordDeployment, Scope{ProjectID: uintPtr(1), Environment: "staging"}, true},
+		{"project and environment grant, project differs", []database.Permission{project1Staging}, auth.PermRecordDeployment, Scope{ProjectID: uintPtr(2), Environment: "staging"}, false},
+		{"any matching grant", []database.Permission{staging, project1}, auth.PermRecordDeployment, Scope{ProjectID: uintPtr(1)}, true},
+	}
+
+	for _, tt := range tests {
+		if got := covers(tt.grants, tt.perm, tt.scope); got != tt.want {
+			t.Errorf("%s: covers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// guardedApp serves path behind guard.RequireScoped for a caller holding grants
+func guardedApp(guard *Guard, perm auth.Permission, resolve ScopeResolver, grants []database.Permission, method string, path string) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c fiber.Ctx) error {
+		c.Locals("permissions", grants)
+		return c.Next()
+	})
+	app.Add([]string{method}, path, func(c fiber.Ctx) error {
+		return c.SendStatus(204)
+	}, guard.RequireScoped(perm, resolve))
+	return app
+}
+
+// status sends a request and returns the response status
+func status(t *testing.T, app *fiber.App, method string, path string, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestRequireScopedProjectParam(t *testing.T) {
+	grants := []database.Permission{{Role: string(auth.RoleReleaseManager), ProjectID: uintPtr(1)}}
+	app := guardedApp(NewGuard(nil), auth.PermManageProjects, ProjectParam("id"), grants, "PUT", "/projects/:id")
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/projects/1", 204},
+		{"/projects/2", 403},
+		{"/projects/abc", 400},
+	}
+	for _, tt := range tests {
+		if got := status(t, app, "PUT", tt.path, ""); got != tt.want {
+			t.Errorf("PUT %s = %d, want %d", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRequireScopedDeploymentBody(t *testing.T) {
+	grants := []database.Permission{
+		{Role: string(auth.RoleDeveloper), ProjectID: uintPtr(1)},
+		{Role: string(auth.RoleReleaseManager), ProjectID: uintPtr(2)},
+	}
+	app := guardedApp(NewGuard([]string{"production"}), auth.PermRecordDeployment, DeploymentBody(), grants, "POST", "/deployments")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"granted project", `{"project_id":1,"environment":"staging"}`, 204},
+		{"other project", `{"project_id":3,"environment":"staging"}`, 403},
+		{"no project", `{"environment":"staging"}`, 403},
+		{"production without deployments.production", `{"project_id":1,"environment":"production"}`, 403},
+		{"production with deployments.production", `{"project_id":2,"environment":"production"}`, 204},
+		{"not JSON", `{`, 400},
+	}
+	for _, tt := range tests {
+		if got := status(t, app, "POST", "/deployments", tt.body); got != tt.want {
+			t.Errorf("%s: POST /deployments = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequireScopedDeploymentParam(t *testing.T) {
+	openTestDB(t)
+	for _, row := range []interface{}{
+		&database.Project{Name: "billing", Version: 1},
+		&database.Project{Name: "payroll", Version: 1},
+		&database.Deployment{ProjectID: 1, Environment: "staging", Version: 1},
+	} {
+		if err := database.DB.Create(row).Error; err != nil {
+			t.Fatalf("failed to create %T: %v", row, err)
+		}
+	}
+
+	grants := []database.Permission{{Role: string(auth.RoleDeveloper), ProjectID: uintPtr(1), Environment: "staging"}}
+	app := guardedApp(NewGuard(nil), auth.PermRecordDeployment, DeploymentParam("id"), grants, "PUT", "/deployments/:id")
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"stored scope", "/deployments/1", `{"notes":"x"}`, 204},
+		{"move to another project", "/deployments/1", `{"project_id":2}`, 403},
+		{"move to another environment", "/deployments/1", `{"environment":"production"}`, 403},
+		{"unknown deployment needs an unscoped grant", "/deployments/99", ``, 403},
+		{"invalid ID", "/deployments/abc", ``, 400},
+	}
+	for _, tt := range tests {
+		if got := status(t, app, "PUT", tt.path, tt.body); got != tt.want {
+			t.Errorf("%s: PUT %s = %d, want %d", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package router
+
+import (
+	"chklst-go/internal/api/handlers"
+	"chklst-go/internal/api/middleware"
+	"chklst-go/internal/auth"
+	"chklst-go/internal/config"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Setup registers the middleware chain and every API route on the app
+func Setup(app *fiber.App, cfg *config.Config, tokens *auth.TokenIssuer, gate *middleware.RequestGate) {
+	// Middleware chain (order matters: request ID first, recovery wraps handlers)
+	app.Use(middleware.RequestID())
+	app.Use(middleware.RequestLogger())
+	app.Use(middleware.Recovery())
+	app.Use(middleware.CORS(cfg.CORSOrigins))
+	app.Use(gate.Handler())
+
+	// Health check
+	app.Get("/health", handlers.HealthCheck)
+
+	// Public routes (registered before the auth middleware)
+	app.Post("/api/v1/auth/login", handlers.Login)
+
+	// Own account (authenticated, no role required)
+	account := app.Group("/api/v1/auth", middleware.Auth(tokens))
+	account.Get("/me", handlers.GetCurrentUser)
+	account.Put("/password", handlers.ChangePassword)
+
+	guard := middleware.NewGuard(cfg.ProductionEnvironments)
+	api := app.Group("/api/v1", middleware.Auth(tokens), guard.RequireAny(auth.PermRead))
+
+	// Users & permissions
+	manageUsers := guard.Require(auth.PermManageUsers)
+	api.Get("/users", handlers.ListUsers, manageUsers)
+	api.Post("/users", handlers.CreateUser, manageUsers)
+	api.Put("/users/:id", handlers.UpdateUser, manageUsers)
+	api.Patch("/users/:id", handlers.PatchUser, manageUsers)
+	api.Delete("/users/:id", handlers.DeleteUser, manageUsers)
+	api.Get("/users/:id/permissions", handlers.ListUserPermissions, manageUsers)
+	api.Post("/users/:id/permissions", handlers.GrantPermission, manageUsers)
+	api.Delete("/users/:id/permissions/:permissionId", handlers.RevokePermission, manageUsers)
+
+	// Projects
+	api.Get("/projects", handlers.ListProjects)
+	api.Post("/projects", handlers.CreateProject, guard.Require(auth.PermManageProjects))
+	api.Get("/projects/:id", handlers.GetProject)
+	api.Put("/projects/:id", handlers.UpdateProject, guard.RequireScoped(auth.PermManageProjects, middleware.ProjectParam("id")))
+	api.Patch("/projects/:id", handlers.PatchProject, guard.RequireScoped(auth.PermManageProjects, middleware.ProjectParam("id")))
+	api.Delete("/projects/:id", handlers.DeleteProject, guard.RequireScoped(auth.PermManageProjects, middleware.ProjectParam("id")))
+
+	// Components
+	manageComponents := guard.RequireScoped(auth.PermManageProjects, middleware.ProjectParam("projectId"))
+	api.Post("/projects/:projectId/components", handlers.CreateComponent, manageComponents)
+	api.Put("/projects/:projectId/components/:componentId", handlers.UpdateComponent, manageComponents)
+	api.Patch("/projects/:projectId/components/:componentId", handlers.PatchComponent, manageComponents)
+	api.Delete("/projects/:projectId/components/:componentId", handlers.DeleteComponent, manageComponents)
+
+	// Deployments
+	recordDeployment := guard.RequireScoped(auth.PermRecordDeployment, middleware.DeploymentParam("id"))
+	api.Get("/deployments", handlers.ListDeployments)
+	api.Post("/deployments", handlers.CreateDeployment, guard.RequireScoped(auth.PermRecordDeployment, middleware.DeploymentBody()))
+	api.Get("/deployments/:id", handlers.GetDeployment)
+	api.Put("/deployments/:id", handlers.UpdateDeployment, recordDeployment)
+	api.Patch("/deployments/:id", handlers.PatchDeployment, recordDeployment)
+	api.Delete("/deployments/:id", handlers.DeleteDeployment, recordDeployment)
+	api.Post("/deployments/:id/transition", handlers.TransitionDeployment, recordDeployment)
+
+	// Checklists
+	api.Get("/deployments/:id/checklist", handlers.GetDeploymentChecklist)
+	api.Post("/deployments/:id/checklist/:itemId/tick", handlers.TickChecklistItem, recordDeployment)
+	api.Delete("/deployments/:id/checklist/:itemId/tick", handlers.UntickChecklistItem, recordDeployment)
+	manageTemplates := guard.Require(auth.PermManageProjects)
+	api.Get("/checklist-templates", handlers.ListChecklistTemplates)
+	api.Post("/checklist-templates", handlers.CreateChecklistTemplate, manageTemplates)
+	api.Get("/checklist-templates/:id", handlers.GetChecklistTemplate)
+	api.Put("/checklist-templates/:id", handlers.UpdateChecklistTemplate, manageTemplates)
+	api.Patch("/checklist-templates/:id", handlers.PatchChecklistTemplate, manageTemplates)
+	api.Delete("/checklist-templates/:id", handlers.DeleteChecklistTemplate, manageTemplates)
+
+	// Library/Presets
+	manageLibrary := guard.Require(auth.PermManageLibrary)
+	api.Get("/library", handlers.GetLibrary)
+	api.Put("/library", handlers.UpdateLibrary, manageLibrary)
+	api.Post("/library/developers", handlers.AddDeveloper, manageLibrary)
+	api.Delete("/library/developers/:name", handlers.RemoveDeveloper, manageLibrary)
+	api.Post("/library/developers/:name/rename", handlers.RenameDeveloper, manageLibrary)
+	api.Post("/library/developers/:name/merge", handlers.MergeDeveloper, manageLibrary)
+	api.Post("/library/build-servers", handlers.AddBuildServer, manageLibrary)
+	api.Delete("/library/build-servers/:name", handlers.RemoveBuildServer, manageLibrary)
+	api.Post("/library/build-servers/:name/rename", handlers.RenameBuildServer, manageLibrary)
+	api.Post("/library/build-servers/:name/merge", handlers.MergeBuildServer, manageLibrary)
+	api.Post("/library/deploy-servers", handlers.AddDeployServer, manageLibrary)
+	api.Delete("/library/deploy-servers/:name", handlers.RemoveDeployServer, manageLibrary)
+	api.Post("/library/deploy-servers/:name/rename", handlers.RenameDeployServer, manageLibrary)
+	api.Post("/library/deploy-servers/:name/merge", handlers.MergeDeployServer, manageLibrary)
+	api.Post("/library/environments", handlers.AddEnvironment, manageLibrary)
+	api.Delete("/library/environments/:name", handlers.RemoveEnvironment, manageLibrary)
+	api.Post("/library/environments/:name/rename", handlers.RenameEnvironment, manageLibrary)
+	api.Post("/library/environments/:name/merge", handlers.MergeEnvironment, manageLibrary)
+
+	// Library entities
+	api.Get("/developers", handlers.ListDevelopers)
+	api.Post("/developers", handlers.CreateDeveloper, manageLibrary)
+	api.Put("/developers/:id", handlers.UpdateDeveloper, manageLibrary)
+	api.Patch("/developers/:id", handlers.PatchDeveloper, manageLibrary)
+	api.Delete("/developers/:id", handlers.DeleteDeveloper, manageLibrary)
+	api.Get("/servers", handlers.ListServers)
+	api.Post("/servers", handlers.CreateServer, manageLibrary)
+	api.Put("/servers/:id", handlers.UpdateServer, manageLibrary)
+	api.Patch("/servers/:id", handlers.PatchServer, manageLibrary)
+	api.Delete("/servers/:id", handlers.DeleteServer, manageLibrary)
+	api.Get("/environments", handlers.ListEnvironments)
+	api.Post("/environments", handlers.CreateEnvironment, manageLibrary)
+	api.Put("/environments/:id", handlers.UpdateEnvironment, manageLibrary)
+	api.Patch("/environments/:id", handlers.PatchEnvironment, manageLibrary)
+	api.Delete("/environments/:id", handlers.DeleteEnvironment, manageLibrary)
+
+	// Settings
+	manageSettings := guard.Require(auth.PermManageSettings)
+	api.Get("/settings", handlers.GetSettings)
+	api.Put("/settings", handlers.UpdateSettings, manageSettings)
+	api.Patch("/settings", handlers.PatchSettings, manageSettings)
+	api.Post("/settings", handlers.UpdateSettings, manageSettings)
+
+	// Full-text search
+	api.Get("/search", handlers.Search)
+
+	// Audit trail
+	api.Get("/audit", handlers.ListAuditEvents, guard.Require(auth.PermViewAudit))
+
+	// Admin
+	admin := api.Group("/admin")
+	manageBackups := guard.Require(auth.PermManageBackups)
+	restore := guard.Require(auth.PermRestore)
+	admin.Post("/backup/database", handlers.BackupDatabase, manageBackups)
+	admin.Post("/restore/database", handlers.RestoreDatabase, restore)
+	admin.Post("/restore/database/upload", handlers.UploadRestoreDatabase, restore)
+	admin.Post("/export/settings", handlers.ExportSettings, manageBackups)
+	admin.Post("/import/settings", handlers.ImportSettings, restore)
+	admin.Post("/import/settings/upload", handlers.UploadImportSettings, restore)
+	admin.Get("/export/bundle", handlers.ExportBundle, manageBackups)
+	admin.Post("/import/bundle", handlers.ImportBundle, restore)
+	admin.Get("/backups", handlers.ListBackups, manageBackups)
+	admin.Get("/backups/remote", handlers.ListRemoteBackups, manageBackups)
+	admin.Get("/backups/jobs", handlers.ListJobs, manageBackups)
+	admin.Put("/backups/jobs/:name", handlers.RescheduleJob, manageBackups)
+	admin.Post("/backups/jobs/:name/start", handlers.StartJob, manageBackups)
+	admin.Post("/backups/jobs/:name/stop", handlers.StopJob, manageBackups)
+	admin.Post("/backups/jobs/:name/run", handlers.RunJob, manageBackups)
+	admin.Get("/backups/:name/verify", handlers.VerifyBackup, manageBackups)
+	admin.Get("/backups/:name/download", handlers.DownloadBackup, manageBackups)
+	admin.Post("/backups/prune", handlers.PruneBackups, manageBackups)
+	admin.Post("/backups/:name/pin", handlers.PinBackup, manageBackups)
+	admin.Delete("/backups/:name/pin", handlers.UnpinBackup, manageBackups)
+}
@@ -0,0 +1,82 @@
+package auth
+
+// Role is a named set of permissions granted to a user
+type Role string
+
+const (
+	RoleViewer         Role = "viewer"
+	RoleDeveloper      Role = "developer"
+	RoleReleaseManager Role = "release_manager"
+	RoleAdmin          Role = "admin"
+)
+
+// Permission is a single capability checked by the API
+type Permission string
+
+const (
+	PermRead             Permission = "read"
+	PermManageProjects   Permission = "projects.write"
+	PermRecordDeployment Permission = "deployments.write"
+	PermDeployProduction Permission = "deployments.production"
+	PermManageLibrary    Permission = "library.write"
+	PermManageSettings   Permission = "settings.write"
+	PermManageBackups    Permission = "backups.write"
+	PermRestore          Permission = "backups.restore"
+	PermViewAudit        Permission = "audit.read"
+	PermManageUsers      Permission = "users.manage"
+)
+
+// rolePermissions lists the permissions each role carries
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {
+		PermRead,
+	},
+	RoleDeveloper: {
+		PermRead,
+		PermRecordDeployment,
+	},
+	RoleReleaseManager: {
+		PermRead,
+		PermManageProjects,
+		PermRecordDeployment,
+		PermDeployProduction,
+		PermManageLibrary,
+		PermManageSettings,
+		PermManageBackups,
+		PermRestore,
+		PermViewAudit,
+	},
+	RoleAdmin: {
+		PermRead,
+		PermManageProjects,
+		PermRecordDeployment,
+		PermDeployProduction,
+		PermManageLibrary,
+		PermManageSettings,
+		PermManageBackups,
+		PermRestore,
+		PermViewAudit,
+		PermManageUsers,
+	},
+}
+
+// IsValid reports whether r is a known role
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Has reports whether the role carries the permission
+func (r Role) Has(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles returns all known roles from least to most privileged
+func Roles() []Role {
+	return []Role{RoleViewer, RoleDeveloper, RoleReleaseManager, RoleAdmin}
+}
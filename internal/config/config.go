@@ -0,0 +1,129 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Version is the application version, overridable at build time with
+// -ldflags "-X chklst-go/internal/config.Version=..."
+var Version = "1.0.0"
+
+// Config holds the runtime configuration of the server
+type Config struct {
+	DBPath          string
+	BackupDir       string
+	Port            string
+	LogLevel        string
+	AutoBackupHours int
+	BackupSchedule  string // cron expression, defaults to @every AutoBackupHours
+	PruneSchedule   string
+	BackupKey       string // comma-separated [id:]key entries, first is active
+	BackupKeyFile   string
+	BackupTargets   string // comma-separated target URLs
+	RetainHourly    int
+	RetainDaily     int
+	RetainWeekly    int
+	RetainMonthly   int
+	MaxUploadMB     int
+	ShutdownTimeout int // seconds
+	AutoMigrate     bool
+	CORSOrigins     []string
+	AuthSecret      string
+	TokenTTLHours   int
+	AdminUsername   string
+	AdminPassword   string
+
+	ProductionEnvironments []string
+}
+
+// Load reads configuration from command-line flags, falling back to
+// environment variables and then to built-in defaults
+func Load(args []string) (*Config, error) {
+	return Parse(flag.NewFlagSet("chklst", flag.ContinueOnError), args)
+}
+
+// Parse registers the configuration flags on fs, which may already hold
+// command-specific flags, and parses args
+func Parse(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := &Config{}
+
+	fs.StringVar(&cfg.DBPath, "db", getEnv("DB_PATH", "./chklst.db"), "SQLite database file path")
+	fs.StringVar(&cfg.BackupDir, "backup-dir", getEnv("BACKUP_DIR", "./backups"), "Backup directory")
+	fs.StringVar(&cfg.Port, "port", getEnv("PORT", "8000"), "HTTP listen port")
+	fs.StringVar(&cfg.LogLevel, "log-level", getEnv("LOG_LEVEL", "INFO"), "Log level (DEBUG, INFO, WARN, ERROR)")
+	fs.IntVar(&cfg.AutoBackupHours, "auto-backup-hours", getEnvInt("AUTO_BACKUP_HOURS", 24), "Auto-backup interval in hours (0 disables)")
+	fs.StringVar(&cfg.BackupSchedule, "backup-schedule", getEnv("BACKUP_SCHEDULE", ""), "Cron expression for automatic backups, e.g. \"0 3 * * *\" (overrides -auto-backup-hours, \"off\" disables)")
+	fs.StringVar(&cfg.PruneSchedule, "prune-schedule", getEnv("PRUNE_SCHEDULE", "@hourly"), "Cron expression for applying the retention policy (\"off\" disables)")
+	fs.IntVar(&cfg.RetainHourly, "retain-hourly", getEnvInt("RETAIN_HOURLY", 24), "Hourly backups to keep")
+	fs.IntVar(&cfg.RetainDaily, "retain-daily", getEnvInt("RETAIN_DAILY", 30), "Daily backups to keep")
+	fs.IntVar(&cfg.RetainWeekly, "retain-weekly", getEnvInt("RETAIN_WEEKLY", 8), "Weekly backups to keep")
+	fs.IntVar(&cfg.RetainMonthly, "retain-monthly", getEnvInt("RETAIN_MONTHLY", 12), "Monthly backups to keep")
+	fs.StringVar(&cfg.BackupKey, "backup-key", getEnv("BACKUP_KEY", ""), "Backup encryption keys as comma-separated [id:]key entries, the first encrypts")
+	fs.StringVar(&cfg.BackupKeyFile, "backup-key-file", getEnv("BACKUP_KEY_FILE", ""), "File with one [id:]key backup encryption key per line")
+	fs.StringVar(&cfg.BackupTargets, "backup-targets", getEnv("BACKUP_TARGETS", ""), "Comma-separated remote backup target URLs (directory, sftp://, s3://)")
+	fs.IntVar(&cfg.MaxUploadMB, "max-upload-mb", getEnvInt("MAX_UPLOAD_MB", 512), "Largest accepted request body in MB, which bounds backup uploads")
+	fs.IntVar(&cfg.ShutdownTimeout, "shutdown-timeout", getEnvInt("SHUTDOWN_TIMEOUT", 10), "Graceful shutdown timeout in seconds")
+
+	fs.BoolVar(&cfg.AutoMigrate, "auto-migrate", getEnv("AUTO_MIGRATE", "true") == "true", "Apply pending schema migrations on startup")
+	fs.StringVar(&cfg.AuthSecret, "auth-secret", getEnv("AUTH_SECRET", ""), "Secret used to sign session tokens (random if empty)")
+	fs.IntVar(&cfg.TokenTTLHours, "token-ttl-hours", getEnvInt("TOKEN_TTL_HOURS", 12), "Session token lifetime in hours")
+	fs.StringVar(&cfg.AdminUsername, "admin-username", getEnv("ADMIN_USERNAME", "admin"), "Username of the initial admin account")
+	fs.StringVar(&cfg.AdminPassword, "admin-password", getEnv("ADMIN_PASSWORD", ""), "Password of the initial admin account (random if empty)")
+	productionEnvs := fs.String("production-envs", getEnv("PRODUCTION_ENVIRONMENTS", "Production"), "Comma-separated environments that require release manager rights")
+	corsOrigins := fs.String("cors-origins", getEnv("CORS_ORIGINS", "*"), "Comma-separated list of allowed CORS origins")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if cfg.BackupSchedule == "" && cfg.AutoBackupHours > 0 {
+		cfg.BackupSchedule = "@every " + strconv.Itoa(cfg.AutoBackupHours) + "h"
+	}
+	cfg.BackupSchedule = schedule(cfg.BackupSchedule)
+	cfg.PruneSchedule = schedule(cfg.PruneSchedule)
+	cfg.CORSOrigins = splitList(*corsOrigins)
+	cfg.ProductionEnvironments = splitList(*productionEnvs)
+
+	return cfg, nil
+}
+
+// schedule normalises a schedule flag, where "off" disables the job
+func schedule(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "off") {
+		return ""
+	}
+	return value
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnv returns the environment variable or a default value
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt returns the environment variable as int or a default value
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
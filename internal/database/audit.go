@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditCreate     = "create"
+	AuditUpdate     = "update"
+	AuditDelete     = "delete"
+	AuditTransition = "transition"
+	AuditRestore    = "restore"
+	AuditImport     = "import"
+	AuditMerge      = "merge"
+)
+
+// FieldChange holds the before and after value of a single field
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditDiff is a custom type for JSON field diffs in SQLite
+type AuditDiff map[string]FieldChange
+
+// Scan implements sql.Scanner interface
+func (d *AuditDiff) Scan(value interface{}) error {
+	if value == nil {
+		*d = AuditDiff{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("failed to unmarshal AuditDiff value")
+	}
+
+	return json.Unmarshal(bytes, d)
+}
+
+// Value implements driver.Valuer interface
+func (d AuditDiff) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(d)
+}
+
+// auditIgnoredFields are bookkeeping fields left out of diffs
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// DiffEntities compares the JSON representation of two entities.
+// Either side may be nil (create/delete). Nested relationships are ignored.
+func DiffEntities(before, after interface{}) (AuditDiff, error) {
+	beforeMap, err := toFieldMap(before)
+	if err != nil {
+		return nil, err
+	}
+	afterMap, err := toFieldMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := AuditDiff{}
+	for key, afterValue := range afterMap {
+		beforeValue, existed := beforeMap[key]
+		if !existed || !reflect.DeepEqual(beforeValue, afterValue) {
+			diff[key] = FieldChange{Before: beforeValue, After: afterValue}
+		}
+	}
+	for key, beforeValue := range beforeMap {
+		if _, exists := afterMap[key]; !exists {
+			diff[key] = FieldChange{Before: beforeValue, After: nil}
+		}
+	}
+
+	return diff, nil
+}
+
+// toFieldMap flattens an entity to its scalar JSON fields
+func toFieldMap(entity interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if entity == nil || (reflect.ValueOf(entity).Kind() == reflect.Ptr && reflect.ValueOf(entity).IsNil()) {
+		return fields, nil
+	}
+
+	bytes, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+
+	for key, value := range raw {
+		if auditIgnoredFields[key] || isRelationship(value) {
+			continue
+		}
+		fields[key] = value
+	}
+
+	return fields, nil
+}
+
+// isRelationship reports whether a JSON value is a nested object or list of objects
+func isRelationship(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(map[string]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AuditEntry describes a mutation to be recorded
+type AuditEntry struct {
+	Actor      string
+	RequestID  string
+	EntityType string
+	EntityID   uint
+	Action     string
+	Before     interface{}
+	After      interface{}
+}
+
+// RecordAudit stores an audit event for the given mutation.
+// Updates that change nothing are not recorded.
+func RecordAudit(tx *gorm.DB, entry AuditEntry) error {
+	changes, err := DiffEntities(entry.Before, entry.After)
+	if err != nil {
+		return err
+	}
+
+	if entry.Action == AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	event := AuditEvent{
+		Timestamp:  time.Now(),
+		Actor:      entry.Actor,
+		RequestID:  entry.RequestID,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Action:     entry.Action,
+		Changes:    changes,
+	}
+
+	return tx.Create(&event).Error
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -19,8 +20,18 @@ func InitDatabase(dbPath string) error {
 	// Configure GORM logger
 	gormLogger := logger.Default.LogMode(logger.Info)
 
+	// Transactions take the write lock when they begin. A deferred
+	// transaction that reads and then writes cannot wait for another writer
+	// and fails with "database is locked" instead.
+	dsn := dbPath
+	if strings.Contains(dsn, "?") {
+		dsn += "&_txlock=immediate"
+	} else {
+		dsn += "?_txlock=immediate"
+	}
+
 	// Open SQLite connection
-	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		Logger:                 gormLogger,
 		SkipDefaultTransaction: true, // Better performance
 		PrepareStmt:            true, // Cache prepared statements
@@ -45,42 +56,39 @@ func InitDatabase(dbPath string) error {
 	return nil
 }
 
-// AutoMigrate runs auto-migration for all models
-func AutoMigrate() error {
-	log.Println("🔄 Running auto-migration...")
-
-	err := DB.AutoMigrate(
-		&Project{},
-		&Component{},
-		&Deployment{},
-		&Library{},
-		&Settings{},
-	)
-
+// Migrate brings the schema up to date on startup. With autoApply disabled
+// it only verifies that no migrations are pending. Either way it refuses to
+// run against a schema written by a newer binary.
+func Migrate(autoApply bool) error {
+	version, err := CheckSchemaVersion()
 	if err != nil {
-		return fmt.Errorf("auto-migration failed: %w", err)
+		return err
+	}
+
+	if err := dropStaleSearchTriggers(); err != nil {
+		return err
 	}
 
-	log.Println("✅ Auto-migration completed")
-
-	// Ensure default library exists
-	var library Library
-	result := DB.First(&library)
-	if result.Error == gorm.ErrRecordNotFound {
-		library = Library{
-			ID:            1,
-			Developers:    StringArray{"Kannan"},
-			BuildServers:  StringArray{"192.168.1.149"},
-			DeployServers: StringArray{},
-			Environments:  StringArray{"QA", "UAT", "Production"},
+	if autoApply {
+		if _, err := MigrateUp(0); err != nil {
+			return err
+		}
+	} else {
+		pending, err := PendingMigrations()
+		if err != nil {
+			return err
 		}
-		if err := DB.Create(&library).Error; err != nil {
-			log.Printf("⚠️  Warning: Failed to create default library: %v", err)
-		} else {
-			log.Println("✅ Default library created")
+		if pending > 0 {
+			return fmt.Errorf("database schema is at version %d with %d pending migration(s); run `chklst migrate up`", version, pending)
 		}
 	}
 
+	log.Printf("✅ Database schema at version %d", LatestSchemaVersion())
+
+	if err := InitSearchIndex(); err != nil {
+		log.Printf("⚠️  Warning: %v", err)
+	}
+
 	return nil
 }
 
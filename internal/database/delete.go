@@ -0,0 +1,62 @@
+package database
+
+import "gorm.io/gorm"
+
+// SQLite is opened without foreign key enforcement, so the cascades the
+// models declare do not run. These deletes remove a row's dependents
+// explicitly, in the caller's transaction.
+
+// DeleteProject deletes a project with its components, deployments and
+// checklist templates, and the permissions scoped to it
+func DeleteProject(tx *gorm.DB, id uint) error {
+	if err := deleteDeployments(tx, "project_id", id); err != nil {
+		return err
+	}
+	if err := deleteChecklistTemplates(tx, "project_id", id); err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", id).Delete(&Component{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id = ?", id).Delete(&Permission{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&Project{}, id).Error
+}
+
+// DeleteComponent deletes a component with its deployments and checklist
+// templates
+func DeleteComponent(tx *gorm.DB, id uint) error {
+	if err := deleteDeployments(tx, "component_id", id); err != nil {
+		return err
+	}
+	if err := deleteChecklistTemplates(tx, "component_id", id); err != nil {
+		return err
+	}
+	return tx.Delete(&Component{}, id).Error
+}
+
+// DeleteDeployment deletes a deployment with its checklist
+func DeleteDeployment(tx *gorm.DB, id uint) error {
+	return deleteDeployments(tx, "id", id)
+}
+
+// deleteDeployments deletes the deployments whose column equals id, with
+// their checklists
+func deleteDeployments(tx *gorm.DB, column string, id uint) error {
+	ids := tx.Model(&Deployment{}).Select("id").Where(column+" = ?", id)
+	if err := tx.Where("deployment_id IN (?)", ids).Delete(&ChecklistItem{}).Error; err != nil {
+		return err
+	}
+	return tx.Where(column+" = ?", id).Delete(&Deployment{}).Error
+}
+
+// deleteChecklistTemplates deletes the checklist templates whose column
+// equals id, with their items
+func deleteChecklistTemplates(tx *gorm.DB, column string, id uint) error {
+	ids := tx.Model(&ChecklistTemplate{}).Select("id").Where(column+" = ?", id)
+	if err := tx.Where("template_id IN (?)", ids).Delete(&ChecklistTemplateItem{}).Error; err != nil {
+		return err
+	}
+	return tx.Where(column+" = ?", id).Delete(&ChecklistTemplate{}).Error
+}
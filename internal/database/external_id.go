@@ -0,0 +1,39 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// External IDs identify projects, components and deployments across chklst
+// instances. Primary keys differ between databases, so bundles refer to rows
+// by external ID and the importer maps them back to local keys.
+
+// newExternalID returns a random version 4 UUID
+func newExternalID() string {
+	return uuid.New().String()
+}
+
+// BeforeCreate assigns an external ID to new projects
+func (p *Project) BeforeCreate(tx *gorm.DB) error {
+	if p.ExternalID == "" {
+		p.ExternalID = newExternalID()
+	}
+	return nil
+}
+
+// BeforeCreate assigns an external ID to new components
+func (c *Component) BeforeCreate(tx *gorm.DB) error {
+	if c.ExternalID == "" {
+		c.ExternalID = newExternalID()
+	}
+	return nil
+}
+
+// BeforeCreate assigns an external ID to new deployments
+func (d *Deployment) BeforeCreate(tx *gorm.DB) error {
+	if d.ExternalID == "" {
+		d.ExternalID = newExternalID()
+	}
+	return nil
+}
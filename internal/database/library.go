@@ -0,0 +1,429 @@
+package database
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Library categories, named after their JSON fields
+const (
+	LibraryDevelopers    = "developers"
+	LibraryBuildServers  = "build_servers"
+	LibraryDeployServers = "deploy_servers"
+	LibraryEnvironments  = "environments"
+)
+
+// LibraryCategories lists the library categories in display order
+var LibraryCategories = []string{
+	LibraryDevelopers,
+	LibraryBuildServers,
+	LibraryDeployServers,
+	LibraryEnvironments,
+}
+
+// Values returns the entries of a library category
+func (l *Library) Values(category string) StringArray {
+	switch category {
+	case LibraryDevelopers:
+		return l.Developers
+	case LibraryBuildServers:
+		return l.BuildServers
+	case LibraryDeployServers:
+		return l.DeployServers
+	case LibraryEnvironments:
+		return l.Environments
+	default:
+		return nil
+	}
+}
+
+// SetValues replaces the entries of a library category
+func (l *Library) SetValues(category string, values StringArray) {
+	switch category {
+	case LibraryDevelopers:
+		l.Developers = values
+	case LibraryBuildServers:
+		l.BuildServers = values
+	case LibraryDeployServers:
+		l.DeployServers = values
+	case LibraryEnvironments:
+		l.Environments = values
+	}
+}
+
+// LibraryReference counts the rows of one entity that use a library value
+// and lists the first referenceIDLimit of their IDs
+type LibraryReference struct {
+	Entity string `json:"entity"`
+	Field  string `json:"field"`
+	Count  int64  `json:"count"`
+	IDs    []uint `json:"ids"`
+}
+
+// referenceIDLimit caps the row IDs listed per LibraryReference
+const referenceIDLimit = 100
+
+// libraryColumn is a column that stores values from a library category,
+// with the foreign key column that points at the same entity
+type libraryColumn struct {
+	entity   string
+	table    string
+	column   string
+	idColumn string
+}
+
+// libraryColumns maps each library category to the columns that use it
+var libraryColumns = map[string][]libraryColumn{
+	LibraryDevelopers: {
+		{"component", "components", "developer", "developer_id"},
+		{"deployment", "deployments", "developer_name", "developer_id"},
+	},
+	LibraryBuildServers: {
+		{"project", "projects", "build_server", "build_server_id"},
+		{"deployment", "deployments", "build_server", "build_server_id"},
+	},
+	LibraryDeployServers: {
+		{"project", "projects", "deploy_server", "deploy_server_id"},
+		{"deployment", "deployments", "deploy_server", "deploy_server_id"},
+	},
+	LibraryEnvironments: {
+		{"project", "projects", "environment", "environment_id"},
+		{"deployment", "deployments", "environment", "environment_id"},
+	},
+}
+
+// libraryTables maps each library category to its entity table
+var libraryTables = map[string]string{
+	LibraryDevelopers:    "developers",
+	LibraryBuildServers:  "servers",
+	LibraryDeployServers: "servers",
+	LibraryEnvironments:  "environments",
+}
+
+// entityColumns maps each entity table to the columns that reference it
+var entityColumns = map[string][]libraryColumn{
+	"developers":   libraryColumns[LibraryDevelopers],
+	"servers":      append(append([]libraryColumn{}, libraryColumns[LibraryBuildServers]...), libraryColumns[LibraryDeployServers]...),
+	"environments": libraryColumns[LibraryEnvironments],
+}
+
+// LibraryReferences returns the rows that use value from a library category,
+// grouped by entity and field. Columns with no matching rows are omitted.
+func LibraryReferences(db *gorm.DB, category string, value string) ([]LibraryReference, error) {
+	return countReferences(db, libraryColumns[category], value)
+}
+
+// LibraryUsage lists the rows that still use one library entry
+type LibraryUsage struct {
+	Category   string             `json:"category"`
+	Value      string             `json:"value"`
+	References []LibraryReference `json:"references"`
+}
+
+// RemovedInUse returns the entries that after drops from before and that
+// rows still use
+func RemovedInUse(db *gorm.DB, before Library, after Library) ([]LibraryUsage, error) {
+	usages := []LibraryUsage{}
+	for _, category := range LibraryCategories {
+		kept := presetNames(after.Values(category))
+		for _, value := range before.Values(category) {
+			if indexOf(kept, value) >= 0 {
+				continue
+			}
+			refs, err := LibraryReferences(db, category, value)
+			if err != nil {
+				return nil, err
+			}
+			if len(refs) > 0 {
+				usages = append(usages, LibraryUsage{Category: category, Value: value, References: refs})
+			}
+		}
+	}
+	return usages, nil
+}
+
+// EntityReferences returns the rows that use the named developer, server or
+// environment in any field. table is "developers", "servers" or "environments".
+func EntityReferences(db *gorm.DB, table string, name string) ([]LibraryReference, error) {
+	return countReferences(db, entityColumns[table], name)
+}
+
+// countReferences counts the rows whose cols hold value
+func countReferences(db *gorm.DB, cols []libraryColumn, value string) ([]LibraryReference, error) {
+	refs := []LibraryReference{}
+	for _, col := range cols {
+		var count int64
+		if err := db.Table(col.table).Where(col.column+" = ?", value).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+
+		ids := []uint{}
+		err := db.Table(col.table).Where(col.column+" = ?", value).Order("id").Limit(referenceIDLimit).Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, LibraryReference{Entity: col.entity, Field: col.column, Count: count, IDs: ids})
+	}
+
+	return refs, nil
+}
+
+// DetachReferences clears the foreign keys that point at an entity about to
+// be deleted. The name columns keep their values.
+func DetachReferences(tx *gorm.DB, table string, id uint) error {
+	return detachColumns(tx, entityColumns[table], id)
+}
+
+// detachColumns clears cols' foreign keys that point at id
+func detachColumns(tx *gorm.DB, cols []libraryColumn, id uint) error {
+	for _, col := range cols {
+		if err := tx.Table(col.table).Where(col.idColumn+" = ?", id).UpdateColumns(map[string]interface{}{col.idColumn: nil, "version": incrementVersion}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadLibrary reads the preset names from the developer, server and
+// environment tables
+func LoadLibrary(db *gorm.DB) (Library, error) {
+	library := Library{
+		Developers:    StringArray{},
+		BuildServers:  StringArray{},
+		DeployServers: StringArray{},
+		Environments:  StringArray{},
+	}
+
+	var developers []Developer
+	if err := db.Order("id").Find(&developers).Error; err != nil {
+		return library, err
+	}
+	for _, d := range developers {
+		library.Developers = append(library.Developers, d.Name)
+	}
+
+	var servers []Server
+	if err := db.Order("id").Find(&servers).Error; err != nil {
+		return library, err
+	}
+	for _, s := range servers {
+		if s.Build {
+			library.BuildServers = append(library.BuildServers, s.Name)
+		}
+		if s.Deploy {
+			library.DeployServers = append(library.DeployServers, s.Name)
+		}
+	}
+
+	var environments []Environment
+	if err := db.Order("position, id").Find(&environments).Error; err != nil {
+		return library, err
+	}
+	for _, e := range environments {
+		library.Environments = append(library.Environments, e.Name)
+	}
+
+	return library, nil
+}
+
+// SaveLibrary makes the entity tables match library. Missing names are
+// created, unlisted developers and environments are deleted, servers take
+// their build/deploy roles from the two server lists and are deleted when
+// they lose both, and environments are ordered as listed. Rows that used
+// a deleted entity keep its name but lose the link.
+func SaveLibrary(db *gorm.DB, library Library) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := saveDevelopers(tx, presetNames(library.Developers)); err != nil {
+			return err
+		}
+		if err := saveServers(tx, presetNames(library.BuildServers), presetNames(library.DeployServers)); err != nil {
+			return err
+		}
+		return saveEnvironments(tx, presetNames(library.Environments))
+	})
+}
+
+// saveDevelopers creates and deletes developers to match names
+func saveDevelopers(tx *gorm.DB, names []string) error {
+	var existing []Developer
+	if err := tx.Find(&existing).Error; err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for _, d := range existing {
+		if indexOf(names, d.Name) >= 0 {
+			found[d.Name] = true
+			continue
+		}
+		if err := removeEntity(tx, "developers", d.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			if err := tx.Create(&Developer{Name: name}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveServers sets server roles from the build and deploy lists, creating
+// and deleting servers as needed
+func saveServers(tx *gorm.DB, build []string, deploy []string) error {
+	var existing []Server
+	if err := tx.Find(&existing).Error; err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for _, s := range existing {
+		isBuild, isDeploy := indexOf(build, s.Name) >= 0, indexOf(deploy, s.Name) >= 0
+		if !isBuild && !isDeploy {
+			// Only servers dropped from both lists go; ones that never had
+			// a role are kept for their metadata
+			if s.Build || s.Deploy {
+				if err := removeEntity(tx, "servers", s.ID); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		found[s.Name] = true
+		if s.Build != isBuild || s.Deploy != isDeploy {
+			err := tx.Model(&s).Updates(map[string]interface{}{"build": isBuild, "deploy": isDeploy, "version": incrementVersion}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range presetNames(append(append([]string{}, build...), deploy...)) {
+		if !found[name] {
+			server := Server{Name: name, Build: indexOf(build, name) >= 0, Deploy: indexOf(deploy, name) >= 0}
+			if err := tx.Create(&server).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveEnvironments creates and deletes environments to match names and
+// numbers them in list order
+func saveEnvironments(tx *gorm.DB, names []string) error {
+	var existing []Environment
+	if err := tx.Find(&existing).Error; err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for _, e := range existing {
+		position := indexOf(names, e.Name) + 1
+		if position == 0 {
+			if err := removeEntity(tx, "environments", e.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		found[e.Name] = true
+		if e.Position != position {
+			if err := tx.Model(&e).Updates(map[string]interface{}{"position": position, "version": incrementVersion}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, name := range names {
+		if !found[name] {
+			if err := tx.Create(&Environment{Name: name, Position: i + 1}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// presetNames trims names and drops empty and repeated ones
+func presetNames(values []string) []string {
+	names := []string{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && indexOf(names, value) < 0 {
+			names = append(names, value)
+		}
+	}
+	return names
+}
+
+// indexOf returns the position of value in values, or -1
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// entityID returns the ID of the entity in table with the given name, or
+// nil when name is empty or unknown
+func entityID(tx *gorm.DB, table string, name string) (*uint, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	var ids []uint
+	db := tx.Session(&gorm.Session{NewDB: true})
+	if err := db.Table(table).Where("name = ?", name).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return &ids[0], nil
+}
+
+// Rows store library values by name and link to the matching entity by ID.
+// The names stay authoritative on writes; the hooks below point the IDs at
+// whatever entity carries the name, or clear them for unknown names.
+
+// BeforeSave links a project to its servers and environment
+func (p *Project) BeforeSave(tx *gorm.DB) (err error) {
+	if p.BuildServerID, err = entityID(tx, "servers", p.BuildServer); err != nil {
+		return err
+	}
+	if p.DeployServerID, err = entityID(tx, "servers", p.DeployServer); err != nil {
+		return err
+	}
+	p.EnvironmentID, err = entityID(tx, "environments", p.Environment)
+	return err
+}
+
+// BeforeSave links a component to its developer
+func (c *Component) BeforeSave(tx *gorm.DB) (err error) {
+	c.DeveloperID, err = entityID(tx, "developers", c.Developer)
+	return err
+}
+
+// BeforeSave links a deployment to its environment, developer and servers
+func (d *Deployment) BeforeSave(tx *gorm.DB) (err error) {
+	if d.EnvironmentID, err = entityID(tx, "environments", d.Environment); err != nil {
+		return err
+	}
+	if d.DeveloperID, err = entityID(tx, "developers", d.DeveloperName); err != nil {
+		return err
+	}
+	if d.BuildServerID, err = entityID(tx, "servers", d.BuildServer); err != nil {
+		return err
+	}
+	d.DeployServerID, err = entityID(tx, "servers", d.DeployServer)
+	return err
+}
@@ -0,0 +1,475 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Library entry errors
+var (
+	ErrLibraryEntryNotFound  = errors.New("library entry not found")
+	ErrLibraryEntryExists    = errors.New("library entry already exists")
+	ErrLibraryEntryInUse     = errors.New("library entry is still in use")
+	ErrSameLibraryEntry      = errors.New("source and target are the same library entry")
+	ErrProductionEnvironment = errors.New("production environments cannot be renamed or merged")
+)
+
+// productionEnvironments are the environments whose deployments need the
+// deployments.production permission. Renaming or merging one would move its
+// deployments and grants past that check, so both are refused.
+var productionEnvironments = map[string]bool{}
+
+// SetProductionEnvironments sets the environments that cannot be renamed or
+// merged
+func SetProductionEnvironments(names []string) {
+	envs := make(map[string]bool, len(names))
+	for _, name := range names {
+		envs[name] = true
+	}
+	productionEnvironments = envs
+}
+
+// checkProductionEnvironment refuses to move rows out of or into a
+// production environment
+func checkProductionEnvironment(fromName string, toName string) error {
+	if fromName != toName && (productionEnvironments[fromName] || productionEnvironments[toName]) {
+		return ErrProductionEnvironment
+	}
+	return nil
+}
+
+// LibraryChange reports a library rename, merge or reassignment and the rows
+// it rewrote, counted per entity. Grants scoped to an environment that is
+// merged away are revoked rather than moved, and listed for review.
+type LibraryChange struct {
+	Category           string           `json:"category,omitempty"`
+	ID                 uint             `json:"id"`
+	From               string           `json:"from"`
+	To                 string           `json:"to"`
+	Changed            int64            `json:"changed"`
+	Rows               map[string]int64 `json:"rows"`
+	RevokedPermissions []Permission     `json:"revoked_permissions,omitempty"`
+}
+
+// RemoveOptions say what happens to rows that still use a removed entry.
+// Without either option removal fails with ErrLibraryEntryInUse.
+type RemoveOptions struct {
+	// Force removes the entry anyway; rows keep its name but are unlinked
+	Force bool
+	// ReassignTo moves the rows to another entry first
+	ReassignTo string
+}
+
+// libraryEntry is the ID and name of a developer, server or environment
+type libraryEntry struct {
+	ID     uint
+	Name   string
+	Build  bool
+	Deploy bool
+}
+
+// findLibraryEntry looks up name in category. Servers must have the role
+// the category stands for unless anyRole is set.
+func findLibraryEntry(tx *gorm.DB, category string, name string, anyRole bool) (*libraryEntry, error) {
+	table, ok := libraryTables[category]
+	if !ok {
+		return nil, fmt.Errorf("unknown library category %q", category)
+	}
+
+	query := tx.Table(table).Where("name = ?", name)
+	if !anyRole {
+		switch category {
+		case LibraryBuildServers:
+			query = query.Where("build = ?", true)
+		case LibraryDeployServers:
+			query = query.Where("deploy = ?", true)
+		}
+	}
+	return firstLibraryEntry(query, table)
+}
+
+// InLibrary reports whether a library category lists name
+func InLibrary(db *gorm.DB, category string, name string) (bool, error) {
+	_, err := findLibraryEntry(db, category, name, false)
+	if errors.Is(err, ErrLibraryEntryNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// firstLibraryEntry returns the first entry query finds in table
+func firstLibraryEntry(query *gorm.DB, table string) (*libraryEntry, error) {
+	if table != "servers" {
+		query = query.Select("id, name")
+	}
+
+	var entries []libraryEntry
+	if err := query.Limit(1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrLibraryEntryNotFound
+	}
+	return &entries[0], nil
+}
+
+// AddLibraryEntry adds name to a library category in a single statement, so
+// concurrent additions cannot lose each other. Adding a known server to the
+// other server category gives it that role. It returns
+// ErrLibraryEntryExists when the category already has name.
+func AddLibraryEntry(db *gorm.DB, category string, name string) error {
+	now := time.Now()
+
+	var result *gorm.DB
+	switch category {
+	case LibraryDevelopers:
+		result = db.Exec(`INSERT INTO developers (name, version, created_at, updated_at) VALUES (?, 1, ?, ?)
+			ON CONFLICT(name) DO NOTHING`, name, now, now)
+	case LibraryBuildServers, LibraryDeployServers:
+		role := "build"
+		if category == LibraryDeployServers {
+			role = "deploy"
+		}
+		result = db.Exec(`INSERT INTO servers (name, build, deploy, version, created_at, updated_at) VALUES (?, ?, ?, 1, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET `+role+` = true, version = version + 1, updated_at = excluded.updated_at
+			WHERE NOT servers.`+role,
+			name, role == "build", role == "deploy", now, now)
+	case LibraryEnvironments:
+		result = db.Exec(`INSERT INTO environments (name, position, version, created_at, updated_at)
+			SELECT ?, COALESCE(MAX(position), 0) + 1, 1, ?, ? FROM environments WHERE true
+			ON CONFLICT(name) DO NOTHING`, name, now, now)
+	default:
+		return fmt.Errorf("unknown library category %q", category)
+	}
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLibraryEntryExists
+	}
+	return nil
+}
+
+// RenameLibraryEntry renames an entry of a library category and rewrites
+// every project, component and deployment that uses the old name, in one
+// transaction. Servers are shared by both server categories, so renaming a
+// build server also renames it where it is used as a deploy server.
+func RenameLibraryEntry(db *gorm.DB, category string, name string, newName string) (*LibraryChange, error) {
+	change := &LibraryChange{Category: category, From: name, To: newName, Rows: map[string]int64{}}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		entry, err := findLibraryEntry(tx, category, name, false)
+		if err != nil {
+			return err
+		}
+		change.ID = entry.ID
+		if newName == name {
+			return nil
+		}
+
+		table := libraryTables[category]
+		var taken int64
+		if err := tx.Table(table).Where("name = ?", newName).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrLibraryEntryExists
+		}
+
+		err = tx.Table(table).Where("id = ?", entry.ID).
+			Updates(map[string]interface{}{"name": newName, "version": incrementVersion, "updated_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+
+		change.Rows, change.Changed, err = ReplaceReferences(tx, table, entry.ID, name, entry.ID, newName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// MergeLibraryEntries points every row that uses name at into instead and
+// deletes name, in one transaction. into may be any entity of the same
+// table; a merged server keeps the roles of both.
+func MergeLibraryEntries(db *gorm.DB, category string, name string, into string) (*LibraryChange, error) {
+	change := &LibraryChange{Category: category, From: name, To: into}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		source, err := findLibraryEntry(tx, category, name, false)
+		if err != nil {
+			return err
+		}
+		target, err := findLibraryEntry(tx, category, into, true)
+		if err != nil {
+			return fmt.Errorf("merge target: %w", err)
+		}
+
+		change.ID = target.ID
+		return mergeEntries(tx, libraryTables[category], source, target, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// mergeEntries moves every reference from source to target, revokes the
+// grants scoped to a source environment and deletes source, recording all
+// of it in change. target inherits source's server roles.
+func mergeEntries(tx *gorm.DB, table string, source *libraryEntry, target *libraryEntry, change *LibraryChange) error {
+	if source.ID == target.ID {
+		return ErrSameLibraryEntry
+	}
+
+	if table == "servers" {
+		err := tx.Table(table).Where("id = ?", target.ID).Updates(map[string]interface{}{
+			"build":      source.Build || target.Build,
+			"deploy":     source.Deploy || target.Deploy,
+			"version":    incrementVersion,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	change.Rows, change.Changed, err = ReplaceReferences(tx, table, source.ID, source.Name, target.ID, target.Name)
+	if err != nil {
+		return err
+	}
+	if table == "environments" {
+		if err := revokePermissionEnvironment(tx, source.Name, change); err != nil {
+			return err
+		}
+	}
+
+	return tx.Exec("DELETE FROM `"+table+"` WHERE id = ?", source.ID).Error
+}
+
+// RemoveLibraryEntry removes name from a library category in one
+// transaction. When rows still use it, it fails with ErrLibraryEntryInUse
+// and returns the references, unless opts forces the removal or reassigns
+// the rows to another entry of the category, which the change reports.
+// Grants scoped to a reassigned environment are revoked, not moved. A
+// server removed from one server category keeps its other role.
+func RemoveLibraryEntry(db *gorm.DB, category string, name string, opts RemoveOptions) (*LibraryChange, []LibraryReference, error) {
+	var change *LibraryChange
+	var refs []LibraryReference
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		source, err := findLibraryEntry(tx, category, name, false)
+		if err != nil {
+			return err
+		}
+
+		table, cols := libraryTables[category], libraryColumns[category]
+		if opts.ReassignTo != "" {
+			target, err := findLibraryEntry(tx, category, opts.ReassignTo, false)
+			if err != nil {
+				return fmt.Errorf("reassign target: %w", err)
+			}
+			if source.ID == target.ID {
+				return ErrSameLibraryEntry
+			}
+			if category == LibraryEnvironments {
+				if err := checkProductionEnvironment(source.Name, target.Name); err != nil {
+					return err
+				}
+			}
+
+			change = &LibraryChange{Category: category, ID: target.ID, From: source.Name, To: target.Name}
+			change.Rows, change.Changed, err = replaceReferences(tx, cols, source.ID, source.Name, target.ID, target.Name)
+			if err != nil {
+				return err
+			}
+			if category == LibraryEnvironments {
+				if err := revokePermissionEnvironment(tx, source.Name, change); err != nil {
+					return err
+				}
+			}
+		} else {
+			if refs, err = LibraryReferences(tx, category, name); err != nil {
+				return err
+			}
+			if len(refs) > 0 && !opts.Force {
+				return ErrLibraryEntryInUse
+			}
+		}
+
+		if err := detachColumns(tx, cols, source.ID); err != nil {
+			return err
+		}
+
+		// Servers only lose the role the category stands for
+		if category == LibraryBuildServers && source.Deploy {
+			return tx.Table(table).Where("id = ?", source.ID).Updates(map[string]interface{}{"build": false, "version": incrementVersion, "updated_at": time.Now()}).Error
+		}
+		if category == LibraryDeployServers && source.Build {
+			return tx.Table(table).Where("id = ?", source.ID).Updates(map[string]interface{}{"deploy": false, "version": incrementVersion, "updated_at": time.Now()}).Error
+		}
+		return removeEntity(tx, table, source.ID)
+	})
+	if err != nil {
+		return nil, refs, err
+	}
+	return change, refs, nil
+}
+
+// DeleteLibraryEntity deletes the developer, server or environment with the
+// given ID, guarded like RemoveLibraryEntry. ReassignTo names any entity of
+// the same table and merges the deleted one into it.
+func DeleteLibraryEntity(db *gorm.DB, table string, id uint, opts RemoveOptions) (*LibraryChange, []LibraryReference, error) {
+	var change *LibraryChange
+	var refs []LibraryReference
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		source, err := firstLibraryEntry(tx.Table(table).Where("id = ?", id), table)
+		if err != nil {
+			return err
+		}
+
+		if opts.ReassignTo != "" {
+			target, err := firstLibraryEntry(tx.Table(table).Where("name = ?", opts.ReassignTo), table)
+			if err != nil {
+				return fmt.Errorf("reassign target: %w", err)
+			}
+
+			change = &LibraryChange{ID: target.ID, From: source.Name, To: target.Name}
+			return mergeEntries(tx, table, source, target, change)
+		}
+
+		if refs, err = EntityReferences(tx, table, source.Name); err != nil {
+			return err
+		}
+		if len(refs) > 0 && !opts.Force {
+			return ErrLibraryEntryInUse
+		}
+		return removeEntity(tx, table, source.ID)
+	})
+	if err != nil {
+		return nil, refs, err
+	}
+	return change, refs, nil
+}
+
+// removeEntity unlinks the rows that reference an entity and deletes it
+func removeEntity(tx *gorm.DB, table string, id uint) error {
+	if err := DetachReferences(tx, table, id); err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM `"+table+"` WHERE id = ?", id).Error
+}
+
+// ReplaceReferences rewrites the rows that use one entity of table to use
+// another. Rows match by foreign key, or by name when they are not linked
+// to any entity. It returns the number of rows changed per entity and in
+// total. Permission grants scoped to a renamed environment follow it, but
+// are left alone when rows move to another environment; production
+// environments fail with ErrProductionEnvironment.
+func ReplaceReferences(tx *gorm.DB, table string, fromID uint, fromName string, toID uint, toName string) (map[string]int64, int64, error) {
+	if fromID == toID && fromName == toName {
+		return map[string]int64{}, 0, nil
+	}
+	if table == "environments" {
+		if err := checkProductionEnvironment(fromName, toName); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	rows, total, err := replaceReferences(tx, entityColumns[table], fromID, fromName, toID, toName)
+	if err != nil || table != "environments" || fromID != toID {
+		return rows, total, err
+	}
+
+	change := &LibraryChange{Rows: rows, Changed: total}
+	err = replacePermissionEnvironment(tx, fromName, toName, change)
+	return change.Rows, change.Changed, err
+}
+
+// replaceReferences rewrites cols from one entity to another
+func replaceReferences(tx *gorm.DB, cols []libraryColumn, fromID uint, fromName string, toID uint, toName string) (map[string]int64, int64, error) {
+	rows := map[string]int64{}
+	var total int64
+
+	// Group the columns by table so a row using the entity twice (a project
+	// built and deployed on the same server) is counted once
+	var tables []string
+	columns := map[string][]libraryColumn{}
+	for _, col := range cols {
+		if _, seen := columns[col.table]; !seen {
+			tables = append(tables, col.table)
+		}
+		columns[col.table] = append(columns[col.table], col)
+	}
+
+	for _, t := range tables {
+		var changed int64
+		where := tx.Session(&gorm.Session{NewDB: true})
+		for _, col := range columns[t] {
+			where = where.Or(col.idColumn+" = ? OR ("+col.idColumn+" IS NULL AND "+col.column+" = ?)", fromID, fromName)
+		}
+		if err := tx.Table(t).Where(where).Count(&changed).Error; err != nil {
+			return nil, 0, err
+		}
+		if changed == 0 {
+			continue
+		}
+
+		for _, col := range columns[t] {
+			err := tx.Table(t).Where(col.idColumn+" = ? OR ("+col.idColumn+" IS NULL AND "+col.column+" = ?)", fromID, fromName).
+				UpdateColumns(map[string]interface{}{col.column: toName, col.idColumn: toID, "version": incrementVersion}).Error
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		rows[columns[t][0].entity] = changed
+		total += changed
+	}
+
+	return rows, total, nil
+}
+
+// replacePermissionEnvironment moves permission grants scoped to a renamed
+// environment to its new name and adds them to change
+func replacePermissionEnvironment(tx *gorm.DB, fromName string, toName string, change *LibraryChange) error {
+	if fromName == toName {
+		return nil
+	}
+
+	result := tx.Table("permissions").Where("environment = ?", fromName).UpdateColumns(map[string]interface{}{"environment": toName, "version": incrementVersion})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		change.Rows["permission"] = result.RowsAffected
+		change.Changed += result.RowsAffected
+	}
+	return nil
+}
+
+// revokePermissionEnvironment deletes the permission grants scoped to an
+// environment whose rows moved to another one and lists them in change.
+// Moving them would let a grant on one environment reach the other's.
+func revokePermissionEnvironment(tx *gorm.DB, name string, change *LibraryChange) error {
+	var grants []Permission
+	if err := tx.Where("environment = ?", name).Order("id").Find(&grants).Error; err != nil {
+		return err
+	}
+	if len(grants) == 0 {
+		return nil
+	}
+
+	if err := tx.Where("environment = ?", name).Delete(&Permission{}).Error; err != nil {
+		return err
+	}
+	change.RevokedPermissions = grants
+	return nil
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newLibraryTestDB opens a migrated test database with the environments
+// staging, qa and production, the build server ci and the deploy server
+// web01. Project 1 and deployment 1 run in staging on ci, deployment 2 in
+// qa, and user 1 holds a grant on each environment.
+func newLibraryTestDB(t *testing.T) {
+	t.Helper()
+	migrateTestDB(t)
+	SetProductionEnvironments([]string{"production"})
+	t.Cleanup(func() { SetProductionEnvironments(nil) })
+
+	if err := SaveLibrary(DB, Library{
+		BuildServers:  StringArray{"ci"},
+		DeployServers: StringArray{"web01"},
+		Environments:  StringArray{"staging", "qa", "production"},
+	}); err != nil {
+		t.Fatalf("SaveLibrary: %v", err)
+	}
+
+	for _, row := range []interface{}{
+		&User{Username: "ana", PasswordHash: "x"},
+		&Permission{UserID: 1, Role: "developer", Environment: "staging"},
+		&Permission{UserID: 1, Role: "developer", Environment: "qa"},
+		&Permission{UserID: 1, Role: "release_manager", Environment: "production"},
+		&Project{Name: "billing", Environment: "staging", BuildServer: "ci", DeployServer: "web01", Version: 1},
+		&Deployment{ProjectID: 1, Environment: "staging", BuildServer: "ci", Version: 1},
+		&Deployment{ProjectID: 1, Environment: "qa", Version: 1},
+	} {
+		if err := DB.Create(row).Error; err != nil {
+			t.Fatalf("failed to create %T: %v", row, err)
+		}
+	}
+}
+
+// environmentOf returns the environment name and ID a row of table stores
+func environmentOf(t *testing.T, table string, id uint) (string, *uint) {
+	t.Helper()
+	var row struct {
+		Environment   string
+		EnvironmentID *uint
+	}
+	if err := DB.Table(table).Select("environment, environment_id").Where("id = ?", id).Scan(&row).Error; err != nil {
+		t.Fatalf("failed to read %s %d: %v", table, id, err)
+	}
+	return row.Environment, row.EnvironmentID
+}
+
+// grantEnvironments returns the environments of user 1's grants
+func grantEnvironments(t *testing.T) []string {
+	t.Helper()
+	var envs []string
+	if err := DB.Model(&Permission{}).Order("id").Pluck("environment", &envs).Error; err != nil {
+		t.Fatalf("failed to read grants: %v", err)
+	}
+	return envs
+}
+
+func TestRenameLibraryEntry(t *testing.T) {
+	newLibraryTestDB(t)
+
+	change, err := RenameLibraryEntry(DB, LibraryEnvironments, "staging", "stage")
+	if err != nil {
+		t.Fatalf("RenameLibraryEntry: %v", err)
+	}
+	if want := map[string]int64{"project": 1, "deployment": 1, "permission": 1}; !reflect.DeepEqual(change.Rows, want) || change.Changed != 3 {
+		t.Fatalf("change = %+v, want rows %v", change, want)
+	}
+
+	// The entity keeps its ID, so the rows stay linked to it
+	for _, table := range []string{"projects", "deployments"} {
+		if name, id := environmentOf(t, table, 1); name != "stage" || id == nil || *id != change.ID {
+			t.Errorf("%s 1 is in %q (%v), want stage (%d)", table, name, id, change.ID)
+		}
+	}
+	if envs := grantEnvironments(t); !reflect.DeepEqual(envs, []string{"stage", "qa", "production"}) {
+		t.Errorf("grants = %v, want the staging grant renamed", envs)
+	}
+	if len(change.RevokedPermissions) != 0 {
+		t.Errorf("a rename revoked %v", change.RevokedPermissions)
+	}
+}
+
+func TestRenameLibraryEntryRefusals(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+		from     string
+		to       string
+		err      error
+	}{
+		{"name taken", LibraryEnvironments, "staging", "qa", ErrLibraryEntryExists},
+		{"unknown entry", LibraryEnvironments, "uat", "test", ErrLibraryEntryNotFound},
+		{"production", LibraryEnvironments, "production", "prod", ErrProductionEnvironment},
+		{"server without the role", LibraryDeployServers, "ci", "build01", ErrLibraryEntryNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newLibraryTestDB(t)
+			if _, err := RenameLibraryEntry(DB, tt.category, tt.from, tt.to); !errors.Is(err, tt.err) {
+				t.Fatalf("RenameLibraryEntry = %v, want %v", err, tt.err)
+			}
+			if name, _ := environmentOf(t, "projects", 1); name != "staging" {
+				t.Fatalf("project 1 moved to %q", name)
+			}
+		})
+	}
+}
+
+func TestMergeLibraryEntries(t *testing.T) {
+	newLibraryTestDB(t)
+
+	change, err := MergeLibraryEntries(DB, LibraryEnvironments, "staging", "qa")
+	if err != nil {
+		t.Fatalf("MergeLibraryEntries: %v", err)
+	}
+	if want := map[string]int64{"project": 1, "deployment": 1}; !reflect.DeepEqual(change.Rows, want) || change.Changed != 2 {
+		t.Fatalf("change = %+v, want rows %v", change, want)
+	}
+
+	for _, table := range []string{"projects", "deployments"} {
+		if name, id := environmentOf(t, table, 1); name != "qa" || id == nil || *id != change.ID {
+			t.Errorf("%s 1 is in %q (%v), want qa (%d)", table, name, id, change.ID)
+		}
+	}
+	if found, _ := InLibrary(DB, LibraryEnvironments, "staging"); found {
+		t.Error("staging is still in the library")
+	}
+
+	// The staging grant is revoked rather than widened to qa
+	if envs := grantEnvironments(t); !reflect.DeepEqual(envs, []string{"qa", "production"}) {
+		t.Errorf("grants = %v, want the staging grant revoked", envs)
+	}
+	if len(change.RevokedPermissions) != 1 || change.RevokedPermissions[0].Environment != "staging" {
+		t.Errorf("revoked = %+v, want the staging grant", change.RevokedPermissions)
+	}
+}
+
+func TestMergeLibraryEntriesRefusals(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		into string
+		err  error
+	}{
+		{"into itself", "staging", "staging", ErrSameLibraryEntry},
+		{"into production", "staging", "production", ErrProductionEnvironment},
+		{"production away", "production", "qa", ErrProductionEnvironment},
+		{"unknown target", "staging", "uat", ErrLibraryEntryNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newLibraryTestDB(t)
+			if _, err := MergeLibraryEntries(DB, LibraryEnvironments, tt.from, tt.into); !errors.Is(err, tt.err) {
+				t.Fatalf("MergeLibraryEntries = %v, want %v", err, tt.err)
+			}
+			if envs := grantEnvironments(t); len(envs) != 3 {
+				t.Fatalf("grants = %v, want all three kept", envs)
+			}
+			if found, _ := InLibrary(DB, LibraryEnvironments, tt.from); !found {
+				t.Fatalf("%s was removed", tt.from)
+			}
+		})
+	}
+}
+
+func TestMergeServersKeepsBothRoles(t *testing.T) {
+	newLibraryTestDB(t)
+
+	if _, err := MergeLibraryEntries(DB, LibraryBuildServers, "ci", "web01"); err != nil {
+		t.Fatalf("MergeLibraryEntries: %v", err)
+	}
+
+	var server Server
+	if err := DB.Where("name = ?", "web01").First(&server).Error; err != nil {
+		t.Fatalf("web01 is gone: %v", err)
+	}
+	if !server.Build || !server.Deploy {
+		t.Fatalf("web01 build %v, deploy %v; want both roles", server.Build, server.Deploy)
+	}
+
+	var project Project
+	DB.First(&project, 1)
+	if project.BuildServer != "web01" || project.DeployServer != "web01" {
+		t.Fatalf("project 1 builds on %q and deploys to %q, want web01 for both", project.BuildServer, project.DeployServer)
+	}
+}
+
+func TestRemoveLibraryEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        RemoveOptions
+		err         error
+		environment string
+		linked      bool
+		grants      []string
+	}{
+		{"in use", RemoveOptions{}, ErrLibraryEntryInUse, "staging", true, []string{"staging", "qa", "production"}},
+		{"forced", RemoveOptions{Force: true}, nil, "staging", false, []string{"staging", "qa", "production"}},
+		{"reassigned", RemoveOptions{ReassignTo: "qa"}, nil, "qa", true, []string{"qa", "production"}},
+		{"reassigned to itself", RemoveOptions{ReassignTo: "staging"}, ErrSameLibraryEntry, "staging", true, []string{"staging", "qa", "production"}},
+		{"reassigned to production", RemoveOptions{ReassignTo: "production"}, ErrProductionEnvironment, "staging", true, []string{"staging", "qa", "production"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newLibraryTestDB(t)
+
+			change, refs, err := RemoveLibraryEntry(DB, LibraryEnvironments, "staging", tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RemoveLibraryEntry = %v, want %v", err, tt.err)
+			}
+			if errors.Is(err, ErrLibraryEntryInUse) && len(refs) != 2 {
+				t.Fatalf("references = %+v, want the project and the deployment", refs)
+			}
+			if tt.opts.ReassignTo == "qa" && (change == nil || len(change.RevokedPermissions) != 1) {
+				t.Fatalf("change = %+v, want the staging grant revoked", change)
+			}
+
+			if name, id := environmentOf(t, "deployments", 1); name != tt.environment || (id != nil) != tt.linked {
+				t.Errorf("deployment 1 is in %q (%v), want %q linked %v", name, id, tt.environment, tt.linked)
+			}
+			if envs := grantEnvironments(t); !reflect.DeepEqual(envs, tt.grants) {
+				t.Errorf("grants = %v, want %v", envs, tt.grants)
+			}
+		})
+	}
+}
+
+func TestRemoveServerRoleKeepsOtherRole(t *testing.T) {
+	newLibraryTestDB(t)
+	if err := DB.Model(&Server{}).Where("name = ?", "ci").Update("deploy", true).Error; err != nil {
+		t.Fatalf("failed to give ci the deploy role: %v", err)
+	}
+
+	if _, _, err := RemoveLibraryEntry(DB, LibraryBuildServers, "ci", RemoveOptions{Force: true}); err != nil {
+		t.Fatalf("RemoveLibraryEntry: %v", err)
+	}
+
+	var server Server
+	if err := DB.Where("name = ?", "ci").First(&server).Error; err != nil {
+		t.Fatalf("ci is gone: %v", err)
+	}
+	if server.Build || !server.Deploy {
+		t.Fatalf("ci build %v, deploy %v; want only deploy", server.Build, server.Deploy)
+	}
+}
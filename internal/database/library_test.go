@@ -0,0 +1,94 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSaveLibrary(t *testing.T) {
+	migrateTestDB(t)
+
+	steps := []struct {
+		name string
+		save Library
+		want Library
+	}{
+		{"create, listing servers in creation order", Library{
+			Developers:    StringArray{"ana", " bo ", "ana", ""},
+			BuildServers:  StringArray{"ci", "shared"},
+			DeployServers: StringArray{"web01", "shared"},
+			Environments:  StringArray{"staging", "qa"},
+		}, Library{
+			Developers:    StringArray{"ana", "bo"},
+			BuildServers:  StringArray{"ci", "shared"},
+			DeployServers: StringArray{"shared", "web01"},
+			Environments:  StringArray{"staging", "qa"},
+		}},
+		{"reorder, drop and change roles", Library{
+			Developers:    StringArray{"bo"},
+			BuildServers:  StringArray{"shared"},
+			DeployServers: StringArray{"web01", "ci"},
+			Environments:  StringArray{"qa", "staging", "production"},
+		}, Library{
+			Developers:    StringArray{"bo"},
+			BuildServers:  StringArray{"shared"},
+			DeployServers: StringArray{"ci", "web01"},
+			Environments:  StringArray{"qa", "staging", "production"},
+		}},
+		{"empty", Library{}, Library{
+			Developers:    StringArray{},
+			BuildServers:  StringArray{},
+			DeployServers: StringArray{},
+			Environments:  StringArray{},
+		}},
+	}
+
+	for _, step := range steps {
+		if err := SaveLibrary(DB, step.save); err != nil {
+			t.Fatalf("%s: SaveLibrary: %v", step.name, err)
+		}
+		got, err := LoadLibrary(DB)
+		if err != nil {
+			t.Fatalf("%s: LoadLibrary: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("%s: library = %+v, want %+v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestRowsLinkToLibraryEntities(t *testing.T) {
+	migrateTestDB(t)
+	if err := SaveLibrary(DB, Library{
+		Developers:    StringArray{"ana"},
+		BuildServers:  StringArray{"ci"},
+		DeployServers: StringArray{"web01"},
+		Environments:  StringArray{"staging"},
+	}); err != nil {
+		t.Fatalf("SaveLibrary: %v", err)
+	}
+
+	project := Project{Name: "billing", BuildServer: "ci", DeployServer: "unknown", Environment: "staging", Version: 1}
+	if err := DB.Create(&project).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	if project.BuildServerID == nil || project.EnvironmentID == nil {
+		t.Fatalf("project not linked: build %v, environment %v", project.BuildServerID, project.EnvironmentID)
+	}
+	if project.DeployServerID != nil {
+		t.Fatalf("unknown deploy server linked to %d", *project.DeployServerID)
+	}
+
+	// Removing an entity keeps the names on its rows but drops the links
+	if err := SaveLibrary(DB, Library{Developers: StringArray{"ana"}, DeployServers: StringArray{"web01"}}); err != nil {
+		t.Fatalf("SaveLibrary: %v", err)
+	}
+	var stored Project
+	DB.First(&stored, project.ID)
+	if stored.BuildServer != "ci" || stored.Environment != "staging" {
+		t.Fatalf("project lost its names: build %q, environment %q", stored.BuildServer, stored.Environment)
+	}
+	if stored.BuildServerID != nil || stored.EnvironmentID != nil {
+		t.Fatalf("project still linked: build %v, environment %v", stored.BuildServerID, stored.EnvironmentID)
+	}
+}
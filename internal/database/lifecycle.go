@@ -0,0 +1,78 @@
+package database
+
+// DeploymentStatus is a state in the deployment lifecycle
+type DeploymentStatus string
+
+const (
+	StatusPending    DeploymentStatus = "pending"
+	StatusBuilding   DeploymentStatus = "building"
+	StatusBuilt      DeploymentStatus = "built"
+	StatusDeploying  DeploymentStatus = "deploying"
+	StatusDeployed   DeploymentStatus = "deployed"
+	StatusFailed     DeploymentStatus = "failed"
+	StatusRolledBack DeploymentStatus = "rolled_back"
+)
+
+// deploymentTransitions lists the legal next states for each state
+var deploymentTransitions = map[DeploymentStatus][]DeploymentStatus{
+	StatusPending:    {StatusBuilding, StatusFailed},
+	StatusBuilding:   {StatusBuilt, StatusFailed},
+	StatusBuilt:      {StatusDeploying, StatusFailed},
+	StatusDeploying:  {StatusDeployed, StatusFailed},
+	StatusDeployed:   {StatusRolledBack},
+	StatusFailed:     {StatusPending},
+	StatusRolledBack: {},
+}
+
+// IsValid reports whether s is a known lifecycle state
+func (s DeploymentStatus) IsValid() bool {
+	_, ok := deploymentTransitions[s]
+	return ok
+}
+
+// AllowedTransitions returns the states reachable from s
+func (s DeploymentStatus) AllowedTransitions() []DeploymentStatus {
+	allowed := deploymentTransitions[s]
+	result := make([]DeploymentStatus, len(allowed))
+	copy(result, allowed)
+	return result
+}
+
+// CanTransitionTo reports whether moving from s to next is legal
+func (s DeploymentStatus) CanTransitionTo(next DeploymentStatus) bool {
+	for _, allowed := range deploymentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyStatus sets the lifecycle state and keeps the legacy
+// BuildStatus/DeployStatus columns in sync for older clients
+func (d *Deployment) ApplyStatus(next DeploymentStatus) {
+	previous := d.Status
+	d.Status = next
+
+	switch next {
+	case StatusPending:
+		d.BuildStatus, d.DeployStatus = "pending", "pending"
+	case StatusBuilding:
+		d.BuildStatus, d.DeployStatus = "building", "pending"
+	case StatusBuilt:
+		d.BuildStatus, d.DeployStatus = "success", "pending"
+	case StatusDeploying:
+		d.BuildStatus, d.DeployStatus = "success", "deploying"
+	case StatusDeployed:
+		d.BuildStatus, d.DeployStatus = "success", "success"
+	case StatusFailed:
+		// A failure before the build finished is a build failure
+		if previous == StatusPending || previous == StatusBuilding {
+			d.BuildStatus, d.DeployStatus = "failed", "pending"
+		} else {
+			d.BuildStatus, d.DeployStatus = "success", "failed"
+		}
+	case StatusRolledBack:
+		d.BuildStatus, d.DeployStatus = "success", "rolled_back"
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeploymentTransitions(t *testing.T) {
+	tests := []struct {
+		from DeploymentStatus
+		to   DeploymentStatus
+		want bool
+	}{
+		{StatusPending, StatusBuilding, true},
+		{StatusPending, StatusFailed, true},
+		{StatusPending, StatusDeployed, false},
+		{StatusBuilding, StatusBuilt, true},
+		{StatusBuilding, StatusDeploying, false},
+		{StatusBuilt, StatusDeploying, true},
+		{StatusBuilt, StatusPending, false},
+		{StatusDeploying, StatusDeployed, true},
+		{StatusDeploying, StatusFailed, true},
+		{StatusDeployed, StatusRolledBack, true},
+		{StatusDeployed, StatusFailed, false},
+		{StatusDeployed, StatusDeployed, false},
+		{StatusFailed, StatusPending, true},
+		{StatusFailed, StatusBuilding, false},
+		{StatusRolledBack, StatusPending, false},
+		{"", StatusPending, false},
+		{StatusPending, "shipped", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%q.CanTransitionTo(%q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestDeploymentStatusIsValid(t *testing.T) {
+	for status := range deploymentTransitions {
+		if !status.IsValid() {
+			t.Errorf("%q.IsValid() = false", status)
+		}
+	}
+	for _, status := range []DeploymentStatus{"", "shipped", "Deployed"} {
+		if status.IsValid() {
+			t.Errorf("%q.IsValid() = true", status)
+		}
+	}
+}
+
+func TestAllowedTransitionsReturnsACopy(t *testing.T) {
+	allowed := StatusPending.AllowedTransitions()
+	if want := []DeploymentStatus{StatusBuilding, StatusFailed}; !reflect.DeepEqual(allowed, want) {
+		t.Fatalf("AllowedTransitions = %v, want %v", allowed, want)
+	}
+
+	allowed[0] = StatusDeployed
+	if StatusPending.CanTransitionTo(StatusDeployed) {
+		t.Fatal("changing the returned slice changed the state machine")
+	}
+	if got := StatusRolledBack.AllowedTransitions(); len(got) != 0 {
+		t.Fatalf("rolled_back allows %v, want nothing", got)
+	}
+}
+
+func TestApplyStatusSyncsLegacyColumns(t *testing.T) {
+	tests := []struct {
+		from         DeploymentStatus
+		to           DeploymentStatus
+		buildStatus  string
+		deployStatus string
+	}{
+		{StatusFailed, StatusPending, "pending", "pending"},
+		{StatusPending, StatusBuilding, "building", "pending"},
+		{StatusBuilding, StatusBuilt, "success", "pending"},
+		{StatusBuilt, StatusDeploying, "success", "deploying"},
+		{StatusDeploying, StatusDeployed, "success", "success"},
+		{StatusPending, StatusFailed, "failed", "pending"},
+		{StatusBuilding, StatusFailed, "failed", "pending"},
+		{StatusDeploying, StatusFailed, "success", "failed"},
+		{StatusDeployed, StatusRolledBack, "success", "rolled_back"},
+	}
+
+	for _, tt := range tests {
+		d := Deployment{Status: tt.from}
+		d.ApplyStatus(tt.to)
+		if d.Status != tt.to || d.BuildStatus != tt.buildStatus || d.DeployStatus != tt.deployStatus {
+			t.Errorf("%s -> %s: status %q, build %q, deploy %q; want build %q, deploy %q",
+				tt.from, tt.to, d.Status, d.BuildStatus, d.DeployStatus, tt.buildStatus, tt.deployStatus)
+		}
+	}
+}
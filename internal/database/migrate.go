@@ -0,0 +1,239 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a numbered, reversible schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"not null" json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// TableName overrides the table name for SchemaMigration
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState describes a known migration and whether it is applied
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer binary
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// LatestSchemaVersion returns the highest migration version known to this binary
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ensureMigrationsTable creates the schema_migrations table
+func ensureMigrationsTable() error {
+	return DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at datetime
+	)`).Error
+}
+
+// SchemaVersion returns the highest applied migration version (0 if none)
+func SchemaVersion() (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := DB.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version).Error
+	return version, err
+}
+
+// CheckSchemaVersion refuses to run against a schema newer than this binary
+func CheckSchemaVersion() (int, error) {
+	version, err := SchemaVersion()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if version > LatestSchemaVersion() {
+		return version, fmt.Errorf("%w: database is at version %d, binary knows up to %d",
+			ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+
+	return version, nil
+}
+
+// MigrateUp applies pending migrations up to target (0 means latest)
+func MigrateUp(target int) (int, error) {
+	if _, err := CheckSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	if err := dropStaleSearchTriggers(); err != nil {
+		return 0, err
+	}
+
+	if target == 0 {
+		target = LatestSchemaVersion()
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.Version > target {
+			break
+		}
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+
+		log.Printf("🔄 Applying migration %03d_%s...", m.Version, m.Name)
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %03d_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	if count > 0 {
+		log.Printf("✅ Applied %d migration(s)", count)
+	}
+
+	return count, nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+func MigrateDown(steps int) (int, error) {
+	if _, err := CheckSchemaVersion(); err != nil {
+		return 0, err
+	}
+
+	if err := dropStaleSearchTriggers(); err != nil {
+		return 0, err
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, done := applied[m.Version]; !done {
+			continue
+		}
+
+		log.Printf("🔄 Reverting migration %03d_%s...", m.Version, m.Name)
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("reverting migration %03d_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// MigrationStatus lists every known migration and whether it is applied
+func MigrationStatus() ([]MigrationState, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if record, done := applied[m.Version]; done {
+			appliedAt := record.AppliedAt
+			state.Applied = true
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// PendingMigrations returns the number of known migrations not yet applied
+func PendingMigrations() (int, error) {
+	states, err := MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, state := range states {
+		if !state.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// appliedMigrations returns the applied migrations keyed by version
+func appliedMigrations() (map[int]SchemaMigration, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	var records []SchemaMigration
+	if err := DB.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// columnExists reports whether table has the given column
+func columnExists(tx *gorm.DB, table, column string) (bool, error) {
+	var count int64
+	err := tx.Raw("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count).Error
+	return count > 0, err
+}
+
+// execAll runs SQL statements in order, stopping at the first error
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
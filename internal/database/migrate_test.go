@@ -0,0 +1,126 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB points DB at an empty database in a temporary directory
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := InitDatabase(filepath.Join(t.TempDir(), "chklst.db")); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	DB.Logger = logger.Discard
+	t.Cleanup(func() { CloseDatabase() })
+}
+
+// migrateTestDB opens a test database at the latest schema version
+func migrateTestDB(t *testing.T) {
+	t.Helper()
+	openTestDB(t)
+	if _, err := MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+}
+
+// schema returns the SQL of every table, index and trigger by name
+func schema(t *testing.T) map[string]string {
+	t.Helper()
+	var rows []struct {
+		Name string
+		SQL  string
+	}
+	err := DB.Raw("SELECT name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'").Scan(&rows).Error
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	objects := make(map[string]string, len(rows))
+	for _, row := range rows {
+		objects[row.Name] = row.SQL
+	}
+	return objects
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	openTestDB(t)
+	if _, err := SchemaVersion(); err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	empty := schema(t) // only schema_migrations
+
+	// Record the schema after each migration on the way up
+	steps := make([]map[string]string, 0, len(migrations))
+	for _, m := range migrations {
+		if _, err := MigrateUp(m.Version); err != nil {
+			t.Fatalf("MigrateUp(%d): %v", m.Version, err)
+		}
+		if version, _ := SchemaVersion(); version != m.Version {
+			t.Fatalf("after MigrateUp(%d) schema version = %d", m.Version, version)
+		}
+		steps = append(steps, schema(t))
+	}
+
+	if pending, err := PendingMigrations(); err != nil || pending != 0 {
+		t.Fatalf("PendingMigrations = %d, %v", pending, err)
+	}
+
+	// Each step down must restore the schema of the step before it
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if n, err := MigrateDown(1); err != nil || n != 1 {
+			t.Fatalf("reverting %03d_%s: reverted %d, %v", m.Version, m.Name, n, err)
+		}
+		want := empty
+		if i > 0 {
+			want = steps[i-1]
+		}
+		if got := schema(t); !reflect.DeepEqual(got, want) {
+			t.Fatalf("schema after reverting %03d_%s differs from before it was applied:\ngot  %v\nwant %v", m.Version, m.Name, got, want)
+		}
+	}
+
+	// And a full run up again ends where the first one did
+	if n, err := MigrateUp(0); err != nil || n != len(migrations) {
+		t.Fatalf("MigrateUp after reverting everything: applied %d, %v", n, err)
+	}
+	if got := schema(t); !reflect.DeepEqual(got, steps[len(steps)-1]) {
+		t.Fatalf("schema after migrating up again differs:\ngot  %v\nwant %v", got, steps[len(steps)-1])
+	}
+}
+
+func TestMigrateUpIsIdempotent(t *testing.T) {
+	migrateTestDB(t)
+	if n, err := MigrateUp(0); err != nil || n != 0 {
+		t.Fatalf("second MigrateUp applied %d, %v", n, err)
+	}
+}
+
+func TestCheckSchemaVersionRefusesNewerSchema(t *testing.T) {
+	migrateTestDB(t)
+	if err := DB.Create(&SchemaMigration{Version: LatestSchemaVersion() + 1, Name: "future"}).Error; err != nil {
+		t.Fatalf("failed to record future migration: %v", err)
+	}
+
+	if _, err := CheckSchemaVersion(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("CheckSchemaVersion = %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := MigrateUp(0); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("MigrateUp = %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := MigrateDown(1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("MigrateDown = %v, want ErrSchemaTooNew", err)
+	}
+}
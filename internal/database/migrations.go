@@ -0,0 +1,409 @@
+package database
+
+import "gorm.io/gorm"
+
+// migrations is the ordered list of schema changes. Never edit an applied
+// migration; add a new one instead. Up steps use IF NOT EXISTS so databases
+// created by the old AutoMigrate are adopted without changes.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `projects` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`build_server` text,`deploy_server` text,`database_name` text,`environment` text,`backup_location` text,`description` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `uni_projects_name` UNIQUE (`name`))",
+				"CREATE INDEX IF NOT EXISTS `idx_projects_name` ON `projects`(`name`)",
+				"CREATE TABLE IF NOT EXISTS `components` (`id` integer PRIMARY KEY AUTOINCREMENT,`project_id` integer NOT NULL,`name` text NOT NULL,`developer` text,`vcs_type` text DEFAULT \"git\",`vcs_url` text,`build_command` text,`component_url` text,`enabled` numeric DEFAULT true,`description` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_projects_components` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`) ON DELETE CASCADE)",
+				"CREATE INDEX IF NOT EXISTS `idx_components_name` ON `components`(`name`)",
+				"CREATE INDEX IF NOT EXISTS `idx_components_project_id` ON `components`(`project_id`)",
+				"CREATE TABLE IF NOT EXISTS `deployments` (`id` integer PRIMARY KEY AUTOINCREMENT,`jira_id` text,`timestamp` datetime,`project_id` integer NOT NULL,`component_id` integer,`environment` text,`vcs_url` text,`developer_name` text,`build_server` text,`deploy_server` text,`database_name` text,`db_backup_location` text,`database_script` text,`previous_build_backup` text,`build_status` text DEFAULT \"pending\",`deploy_status` text DEFAULT \"pending\",`notes` text,`deployed_by` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_components_deployments` FOREIGN KEY (`component_id`) REFERENCES `components`(`id`) ON DELETE CASCADE,CONSTRAINT `fk_projects_deployments` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`) ON DELETE CASCADE)",
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_project_id` ON `deployments`(`project_id`)",
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_timestamp` ON `deployments`(`timestamp`)",
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_jira_id` ON `deployments`(`jira_id`)",
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_component_id` ON `deployments`(`component_id`)",
+				"CREATE TABLE IF NOT EXISTS `library` (`id` integer PRIMARY KEY AUTOINCREMENT,`developers` json,`build_servers` json,`deploy_servers` json,`environments` json,`created_at` datetime,`updated_at` datetime)",
+				"CREATE TABLE IF NOT EXISTS `settings` (`id` integer PRIMARY KEY AUTOINCREMENT,`default_deployed_by` text,`excel_export_path` text,`auto_clear_after_save` numeric,`created_at` datetime,`updated_at` datetime)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"DROP TABLE IF EXISTS `settings`",
+				"DROP TABLE IF EXISTS `library`",
+				"DROP TABLE IF EXISTS `deployments`",
+				"DROP TABLE IF EXISTS `components`",
+				"DROP TABLE IF EXISTS `projects`",
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "seed_default_library",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`INSERT INTO library (id, developers, build_servers, deploy_servers, environments, created_at, updated_at)
+				SELECT 1, '["Kannan"]', '["192.168.1.149"]', '[]', '["QA","UAT","Production"]', datetime('now'), datetime('now')
+				WHERE NOT EXISTS (SELECT 1 FROM library)`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DELETE FROM library WHERE id = 1").Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "deployment_lifecycle_status",
+		Up: func(tx *gorm.DB) error {
+			exists, err := columnExists(tx, "deployments", "status")
+			if err != nil {
+				return err
+			}
+			if !exists {
+				if err := tx.Exec("ALTER TABLE `deployments` ADD `status` text DEFAULT \"pending\"").Error; err != nil {
+					return err
+				}
+			}
+
+			// Derive the lifecycle state of legacy rows from the free-form status columns
+			return execAll(tx,
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_status` ON `deployments`(`status`)",
+				`UPDATE deployments SET status = CASE
+					WHEN deploy_status = 'success' THEN 'deployed'
+					WHEN deploy_status = 'failed' OR build_status = 'failed' THEN 'failed'
+					WHEN build_status = 'success' THEN 'built'
+					ELSE 'pending'
+				END
+				WHERE status IS NULL OR status = '' OR status = 'pending'`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"DROP INDEX IF EXISTS `idx_deployments_status`",
+				"ALTER TABLE `deployments` DROP COLUMN `status`",
+			)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "checklists",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `checklist_templates` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`project_id` integer,`component_id` integer,`description` text,`created_at` datetime,`updated_at` datetime)",
+				"CREATE INDEX IF NOT EXISTS `idx_checklist_templates_component_id` ON `checklist_templates`(`component_id`)",
+				"CREATE INDEX IF NOT EXISTS `idx_checklist_templates_project_id` ON `checklist_templates`(`project_id`)",
+				"CREATE TABLE IF NOT EXISTS `checklist_template_items` (`id` integer PRIMARY KEY AUTOINCREMENT,`template_id` integer NOT NULL,`position` integer,`title` text NOT NULL,`description` text,`required` numeric,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_checklist_templates_items` FOREIGN KEY (`template_id`) REFERENCES `checklist_templates`(`id`) ON DELETE CASCADE)",
+				"CREATE INDEX IF NOT EXISTS `idx_checklist_template_items_template_id` ON `checklist_template_items`(`template_id`)",
+				"CREATE TABLE IF NOT EXISTS `checklist_items` (`id` integer PRIMARY KEY AUTOINCREMENT,`deployment_id` integer NOT NULL,`template_item_id` integer,`position` integer,`title` text NOT NULL,`description` text,`required` numeric,`checked` numeric DEFAULT false,`checked_by` text,`checked_at` datetime,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_deployments_checklist` FOREIGN KEY (`deployment_id`) REFERENCES `deployments`(`id`) ON DELETE CASCADE)",
+				"CREATE INDEX IF NOT EXISTS `idx_checklist_items_deployment_id` ON `checklist_items`(`deployment_id`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"DROP TABLE IF EXISTS `checklist_items`",
+				"DROP TABLE IF EXISTS `checklist_template_items`",
+				"DROP TABLE IF EXISTS `checklist_templates`",
+			)
+		},
+	},
+	{
+		Version: 5,
+		Name:    "audit_events",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `audit_events` (`id` integer PRIMARY KEY AUTOINCREMENT,`timestamp` datetime,`actor` text,`request_id` text,`entity_type` text NOT NULL,`entity_id` integer,`action` text NOT NULL,`changes` json)",
+				"CREATE INDEX IF NOT EXISTS `idx_audit_events_request_id` ON `audit_events`(`request_id`)",
+				"CREATE INDEX IF NOT EXISTS `idx_audit_events_actor` ON `audit_events`(`actor`)",
+				"CREATE INDEX IF NOT EXISTS `idx_audit_events_timestamp` ON `audit_events`(`timestamp`)",
+				"CREATE INDEX IF NOT EXISTS `idx_audit_entity` ON `audit_events`(`entity_type`,`entity_id`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE IF EXISTS `audit_events`").Error
+		},
+	},
+	{
+		Version: 6,
+		Name:    "users",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`username` text NOT NULL,`display_name` text,`password_hash` text NOT NULL,`disabled` numeric DEFAULT false,`last_login_at` datetime,`created_at` datetime,`updated_at` datetime,CONSTRAINT `uni_users_username` UNIQUE (`username`))",
+				"CREATE INDEX IF NOT EXISTS `idx_users_username` ON `users`(`username`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE IF EXISTS `users`").Error
+		},
+	},
+	{
+		Version: 7,
+		Name:    "permissions",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `permissions` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer NOT NULL,`role` text NOT NULL,`project_id` integer,`environment` text,`created_at` datetime,`updated_at` datetime)",
+				"CREATE INDEX IF NOT EXISTS `idx_permissions_project_id` ON `permissions`(`project_id`)",
+				"CREATE INDEX IF NOT EXISTS `idx_permissions_user_id` ON `permissions`(`user_id`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE IF EXISTS `permissions`").Error
+		},
+	},
+	{
+		Version: 8,
+		Name:    "deployment_filter_indexes",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_environment` ON `deployments`(`environment`)",
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_developer_name` ON `deployments`(`developer_name`)",
+				"CREATE INDEX IF NOT EXISTS `idx_deployments_deployed_by` ON `deployments`(`deployed_by`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"DROP INDEX IF EXISTS `idx_deployments_environment`",
+				"DROP INDEX IF EXISTS `idx_deployments_developer_name`",
+				"DROP INDEX IF EXISTS `idx_deployments_deployed_by`",
+			)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "external_ids",
+		Up: func(tx *gorm.DB) error {
+			// Existing rows get random version 4 UUIDs, like new rows get from BeforeCreate
+			const newUUID = "lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))"
+
+			for _, table := range []string{"projects", "components", "deployments"} {
+				exists, err := columnExists(tx, table, "external_id")
+				if err != nil {
+					return err
+				}
+				if !exists {
+					if err := tx.Exec("ALTER TABLE `" + table + "` ADD `external_id` text").Error; err != nil {
+						return err
+					}
+				}
+				err = execAll(tx,
+					"UPDATE `"+table+"` SET external_id = "+newUUID+" WHERE external_id IS NULL OR external_id = ''",
+					"CREATE UNIQUE INDEX IF NOT EXISTS `idx_"+table+"_external_id` ON `"+table+"`(`external_id`)",
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"DROP INDEX IF EXISTS `idx_deployments_external_id`",
+				"DROP INDEX IF EXISTS `idx_components_external_id`",
+				"DROP INDEX IF EXISTS `idx_projects_external_id`",
+				"ALTER TABLE `deployments` DROP COLUMN `external_id`",
+				"ALTER TABLE `components` DROP COLUMN `external_id`",
+				"ALTER TABLE `projects` DROP COLUMN `external_id`",
+			)
+		},
+	},
+	{
+		Version: 10,
+		Name:    "library_entities",
+		Up:      libraryEntitiesUp,
+		Down:    libraryEntitiesDown,
+	},
+	{
+		Version: 11,
+		Name:    "row_versions",
+		Up: func(tx *gorm.DB) error {
+			for _, table := range versionedTables {
+				exists, err := columnExists(tx, table, "version")
+				if err != nil {
+					return err
+				}
+				if !exists {
+					if err := tx.Exec("ALTER TABLE `" + table + "` ADD `version` integer NOT NULL DEFAULT 1").Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range versionedTables {
+				if err := tx.Exec("ALTER TABLE `" + table + "` DROP COLUMN `version`").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: searchMigration,
+		Name:    "search_index",
+		Up:      searchIndexUp,
+		Down:    searchIndexDown,
+	},
+	{
+		Version: 13,
+		Name:    "password_changed_at",
+		Up: func(tx *gorm.DB) error {
+			exists, err := columnExists(tx, "users", "password_changed_at")
+			if err != nil || exists {
+				return err
+			}
+			return tx.Exec("ALTER TABLE `users` ADD `password_changed_at` datetime").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE `users` DROP COLUMN `password_changed_at`").Error
+		},
+	},
+}
+
+// versionedTables are the tables that migration 11 gives a version column
+var versionedTables = []string{
+	"projects", "components", "deployments",
+	"checklist_templates", "checklist_template_items", "checklist_items",
+	"users", "permissions",
+	"developers", "servers", "environments",
+	"settings",
+}
+
+// libraryForeignKeys lists the columns added by migration 10 that point rows at
+// library entities, next to the name columns they are backfilled from
+var libraryForeignKeys = []struct {
+	table, column, refTable, nameColumn string
+}{
+	{"projects", "build_server_id", "servers", "build_server"},
+	{"projects", "deploy_server_id", "servers", "deploy_server"},
+	{"projects", "environment_id", "environments", "environment"},
+	{"components", "developer_id", "developers", "developer"},
+	{"deployments", "environment_id", "environments", "environment"},
+	{"deployments", "developer_id", "developers", "developer_name"},
+	{"deployments", "build_server_id", "servers", "build_server"},
+	{"deployments", "deploy_server_id", "servers", "deploy_server"},
+}
+
+// libraryEntitiesUp moves the library JSON arrays into developer, server and
+// environment tables, adds entities for names that rows use but the library
+// lacked, and links rows to them by ID
+func libraryEntitiesUp(tx *gorm.DB) error {
+	err := execAll(tx,
+		"CREATE TABLE IF NOT EXISTS `developers` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`email` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `uni_developers_name` UNIQUE (`name`))",
+		"CREATE TABLE IF NOT EXISTS `servers` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`hostname` text,`ip_address` text,`os` text,`owner` text,`build` numeric DEFAULT false,`deploy` numeric DEFAULT false,`description` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `uni_servers_name` UNIQUE (`name`))",
+		"CREATE TABLE IF NOT EXISTS `environments` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`position` integer,`description` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `uni_environments_name` UNIQUE (`name`))",
+	)
+	if err != nil {
+		return err
+	}
+
+	// Library entries first, in list order
+	if tx.Migrator().HasTable("library") {
+		err = execAll(tx,
+			`INSERT OR IGNORE INTO developers (name, created_at, updated_at)
+				SELECT trim(j.value), datetime('now'), datetime('now') FROM library, json_each(library.developers) j
+				WHERE trim(j.value) <> '' ORDER BY library.id, j.key`,
+			`INSERT OR IGNORE INTO servers (name, build, deploy, created_at, updated_at)
+				SELECT trim(j.value), true, false, datetime('now'), datetime('now') FROM library, json_each(library.build_servers) j
+				WHERE trim(j.value) <> '' ORDER BY library.id, j.key`,
+			`INSERT OR IGNORE INTO servers (name, build, deploy, created_at, updated_at)
+				SELECT trim(j.value), false, true, datetime('now'), datetime('now') FROM library, json_each(library.deploy_servers) j
+				WHERE trim(j.value) <> '' ORDER BY library.id, j.key`,
+			`UPDATE servers SET deploy = true WHERE name IN
+				(SELECT trim(j.value) FROM library, json_each(library.deploy_servers) j)`,
+			`INSERT OR IGNORE INTO environments (name, position, created_at, updated_at)
+				SELECT trim(j.value), j.key + 1, datetime('now'), datetime('now') FROM library, json_each(library.environments) j
+				WHERE trim(j.value) <> '' ORDER BY library.id, j.key`,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Then names that rows use but the library no longer lists
+	err = execAll(tx,
+		`INSERT OR IGNORE INTO developers (name, created_at, updated_at)
+			SELECT name, datetime('now'), datetime('now') FROM (
+				SELECT developer AS name, id FROM components UNION ALL SELECT developer_name, id FROM deployments
+			) WHERE name IS NOT NULL AND name <> '' GROUP BY name ORDER BY MIN(id)`,
+		`INSERT OR IGNORE INTO servers (name, build, deploy, created_at, updated_at)
+			SELECT name, false, false, datetime('now'), datetime('now') FROM (
+				SELECT build_server AS name, id FROM projects UNION ALL SELECT deploy_server, id FROM projects
+				UNION ALL SELECT build_server, id FROM deployments UNION ALL SELECT deploy_server, id FROM deployments
+			) WHERE name IS NOT NULL AND name <> '' GROUP BY name ORDER BY MIN(id)`,
+		`UPDATE servers SET build = true WHERE name IN
+			(SELECT build_server FROM projects UNION SELECT build_server FROM deployments)`,
+		`UPDATE servers SET deploy = true WHERE name IN
+			(SELECT deploy_server FROM projects UNION SELECT deploy_server FROM deployments)`,
+		`INSERT OR IGNORE INTO environments (name, created_at, updated_at)
+			SELECT name, datetime('now'), datetime('now') FROM (
+				SELECT environment AS name, id FROM projects UNION ALL SELECT environment, id FROM deployments
+			) WHERE name IS NOT NULL AND name <> '' GROUP BY name ORDER BY MIN(id)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Number environments 1..n, keeping library order and appending the rest
+	var envIDs []uint
+	if err := tx.Raw("SELECT id FROM environments ORDER BY position IS NULL, position, id").Scan(&envIDs).Error; err != nil {
+		return err
+	}
+	for i, id := range envIDs {
+		if err := tx.Exec("UPDATE environments SET position = ? WHERE id = ?", i+1, id).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, fk := range libraryForeignKeys {
+		exists, err := columnExists(tx, fk.table, fk.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			err := tx.Exec("ALTER TABLE `" + fk.table + "` ADD `" + fk.column + "` integer REFERENCES `" + fk.refTable + "`(`id`) ON DELETE SET NULL").Error
+			if err != nil {
+				return err
+			}
+		}
+		err = execAll(tx,
+			"UPDATE `"+fk.table+"` SET `"+fk.column+"` = (SELECT id FROM `"+fk.refTable+"` WHERE name = `"+fk.table+"`.`"+fk.nameColumn+"`)",
+			"CREATE INDEX IF NOT EXISTS `idx_"+fk.table+"_"+fk.column+"` ON `"+fk.table+"`(`"+fk.column+"`)",
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Exec("DROP TABLE IF EXISTS `library`").Error
+}
+
+// libraryEntitiesDown rebuilds the library JSON arrays from the entity tables
+// and drops them. Entity metadata is lost.
+func libraryEntitiesDown(tx *gorm.DB) error {
+	err := execAll(tx,
+		"CREATE TABLE IF NOT EXISTS `library` (`id` integer PRIMARY KEY AUTOINCREMENT,`developers` json,`build_servers` json,`deploy_servers` json,`environments` json,`created_at` datetime,`updated_at` datetime)",
+		`INSERT INTO library (id, developers, build_servers, deploy_servers, environments, created_at, updated_at) SELECT 1,
+			(SELECT json_group_array(name) FROM (SELECT name FROM developers ORDER BY id)),
+			(SELECT json_group_array(name) FROM (SELECT name FROM servers WHERE build ORDER BY id)),
+			(SELECT json_group_array(name) FROM (SELECT name FROM servers WHERE deploy ORDER BY id)),
+			(SELECT json_group_array(name) FROM (SELECT name FROM environments ORDER BY position, id)),
+			datetime('now'), datetime('now')
+			WHERE NOT EXISTS (SELECT 1 FROM library)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := len(libraryForeignKeys) - 1; i >= 0; i-- {
+		fk := libraryForeignKeys[i]
+		err := execAll(tx,
+			"DROP INDEX IF EXISTS `idx_"+fk.table+"_"+fk.column+"`",
+			"ALTER TABLE `"+fk.table+"` DROP COLUMN `"+fk.column+"`",
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return execAll(tx,
+		"DROP TABLE IF EXISTS `environments`",
+		"DROP TABLE IF EXISTS `servers`",
+		"DROP TABLE IF EXISTS `developers`",
+	)
+}
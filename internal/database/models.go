@@ -40,16 +40,21 @@ func (a StringArray) Value() (driver.Value, error) {
 
 // Project represents a deployment project
 type Project struct {
-	ID             uint         `gorm:"primaryKey" json:"id"`
-	Name           string       `gorm:"unique;not null;index" json:"name"`
-	BuildServer    string       `json:"build_server"`
-	DeployServer   string       `json:"deploy_server"`
-	DatabaseName   string       `json:"database_name"`
-	Environment    string       `json:"environment"`
-	BackupLocation string       `json:"backup_location"`
-	Description    string       `gorm:"type:text" json:"description"`
-	CreatedAt      time.Time    `json:"created_at"`
-	UpdatedAt      time.Time    `json:"updated_at"`
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ExternalID     string    `gorm:"uniqueIndex" json:"external_id"`
+	Name           string    `gorm:"unique;not null;index" json:"name" validate:"required"`
+	BuildServer    string    `json:"build_server" validate:"library=build_servers"`
+	DeployServer   string    `json:"deploy_server" validate:"library=deploy_servers"`
+	DatabaseName   string    `json:"database_name"`
+	Environment    string    `json:"environment" validate:"library=environments"`
+	BackupLocation string    `json:"backup_location"`
+	BuildServerID  *uint     `gorm:"index" json:"build_server_id"`
+	DeployServerID *uint     `gorm:"index" json:"deploy_server_id"`
+	EnvironmentID  *uint     `gorm:"index" json:"environment_id"`
+	Description    string    `gorm:"type:text" json:"description"`
+	Version        uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 
 	// Relationships
 	Components  []Component  `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE" json:"components,omitempty"`
@@ -59,15 +64,18 @@ type Project struct {
 // Component represents a project component
 type Component struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
-	ProjectID    uint      `gorm:"not null;index" json:"project_id"`
-	Name         string    `gorm:"not null;index" json:"name"`
-	Developer    string    `json:"developer"`
+	ExternalID   string    `gorm:"uniqueIndex" json:"external_id"`
+	ProjectID    uint      `gorm:"not null;index" json:"project_id" validate:"required,exists=projects"`
+	Name         string    `gorm:"not null;index" json:"name" validate:"required"`
+	Developer    string    `json:"developer" validate:"library=developers"`
+	DeveloperID  *uint     `gorm:"index" json:"developer_id"`
 	VCSType      string    `gorm:"default:'git'" json:"vcs_type"` // git, svn, etc.
 	VCSURL       string    `json:"vcs_url"`
 	BuildCommand string    `json:"build_command"`
 	ComponentURL string    `json:"component_url"`
 	Enabled      bool      `gorm:"default:true" json:"enabled"`
 	Description  string    `gorm:"type:text" json:"description"`
+	Version      uint      `gorm:"not null;default:1" json:"version"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 
@@ -78,46 +86,178 @@ type Component struct {
 
 // Deployment represents a deployment record
 type Deployment struct {
-	ID                   uint       `gorm:"primaryKey" json:"id"`
-	JiraID               string     `gorm:"index" json:"jira_id"`
-	Timestamp            time.Time  `gorm:"index" json:"timestamp"`
-	ProjectID            uint       `gorm:"not null;index" json:"project_id"`
-	ComponentID          *uint      `gorm:"index" json:"component_id"` // Nullable for legacy data
-	Environment          string     `json:"environment"`
-	VCSURL               string     `json:"vcs_url"`
-	DeveloperName        string     `json:"developer_name"`
-	BuildServer          string     `json:"build_server"`
-	DeployServer         string     `json:"deploy_server"`
-	DatabaseName         string     `json:"database_name"`
-	DBBackupLocation     string     `json:"db_backup_location"`
-	DatabaseScript       string     `gorm:"type:text" json:"database_script"`
-	PreviousBuildBackup  string     `json:"previous_build_backup"`
-	BuildStatus          string     `gorm:"default:'pending'" json:"build_status"`
-	DeployStatus         string     `gorm:"default:'pending'" json:"deploy_status"`
-	Notes                string     `gorm:"type:text" json:"notes"`
-	DeployedBy           string     `json:"deployed_by"`
-	CreatedAt            time.Time  `json:"created_at"`
-	UpdatedAt            time.Time  `json:"updated_at"`
+	ID                  uint             `gorm:"primaryKey" json:"id"`
+	ExternalID          string           `gorm:"uniqueIndex" json:"external_id"`
+	JiraID              string           `gorm:"index" json:"jira_id" validate:"jira"`
+	Timestamp           time.Time        `gorm:"index" json:"timestamp"`
+	ProjectID           uint             `gorm:"not null;index" json:"project_id" validate:"required,exists=projects"`
+	ComponentID         *uint            `gorm:"index" json:"component_id" validate:"exists=components,belongs=project_id:ProjectID"` // Nullable for legacy data
+	Environment         string           `gorm:"index" json:"environment" validate:"library=environments"`
+	VCSURL              string           `json:"vcs_url"`
+	DeveloperName       string           `gorm:"index" json:"developer_name" validate:"library=developers"`
+	BuildServer         string           `json:"build_server" validate:"library=build_servers"`
+	DeployServer        string           `json:"deploy_server" validate:"library=deploy_servers"`
+	DatabaseName        string           `json:"database_name"`
+	DBBackupLocation    string           `json:"db_backup_location"`
+	DatabaseScript      string           `gorm:"type:text" json:"database_script"`
+	PreviousBuildBackup string           `json:"previous_build_backup"`
+	Status              DeploymentStatus `gorm:"default:'pending';index" json:"status"`
+	BuildStatus         string           `gorm:"default:'pending'" json:"build_status"`
+	DeployStatus        string           `gorm:"default:'pending'" json:"deploy_status"`
+	Notes               string           `gorm:"type:text" json:"notes"`
+	DeployedBy          string           `gorm:"index" json:"deployed_by"`
+	EnvironmentID       *uint            `gorm:"index" json:"environment_id"`
+	DeveloperID         *uint            `gorm:"index" json:"developer_id"`
+	BuildServerID       *uint            `gorm:"index" json:"build_server_id"`
+	DeployServerID      *uint            `gorm:"index" json:"deploy_server_id"`
+	Version             uint             `gorm:"not null;default:1" json:"version"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
 
 	// Relationships
-	Project   Project    `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
-	Component *Component `gorm:"foreignKey:ComponentID" json:"component,omitempty"`
+	Project   Project         `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	Component *Component      `gorm:"foreignKey:ComponentID" json:"component,omitempty"`
+	Checklist []ChecklistItem `gorm:"foreignKey:DeploymentID;constraint:OnDelete:CASCADE" json:"checklist,omitempty"`
 }
 
-// Library stores preset values for dropdowns
-type Library struct {
-	ID            uint        `gorm:"primaryKey" json:"id"`
-	Developers    StringArray `gorm:"type:json" json:"developers"`
-	BuildServers  StringArray `gorm:"type:json" json:"build_servers"`
-	DeployServers StringArray `gorm:"type:json" json:"deploy_servers"`
-	Environments  StringArray `gorm:"type:json" json:"environments"`
-	CreatedAt     time.Time   `json:"created_at"`
-	UpdatedAt     time.Time   `json:"updated_at"`
+// ChecklistTemplate is a reusable checklist attached to a project or component.
+// A template with neither ProjectID nor ComponentID is the global default.
+type ChecklistTemplate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"not null" json:"name"`
+	ProjectID   *uint     `gorm:"index" json:"project_id" validate:"exists=projects"`
+	ComponentID *uint     `gorm:"index" json:"component_id" validate:"exists=components,belongs=project_id:ProjectID"`
+	Description string    `gorm:"type:text" json:"description"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relationships
+	Items []ChecklistTemplateItem `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+// ChecklistTemplateItem is a single step of a checklist template
+type ChecklistTemplateItem struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TemplateID  uint      `gorm:"not null;index" json:"template_id"`
+	Position    int       `json:"position"`
+	Title       string    `gorm:"not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	Required    bool      `json:"required"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ChecklistItem is a checklist step instantiated for a deployment
+type ChecklistItem struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	DeploymentID   uint       `gorm:"not null;index" json:"deployment_id"`
+	TemplateItemID *uint      `json:"template_item_id"`
+	Position       int        `json:"position"`
+	Title          string     `gorm:"not null" json:"title"`
+	Description    string     `gorm:"type:text" json:"description"`
+	Required       bool       `json:"required"`
+	Checked        bool       `gorm:"default:false" json:"checked"`
+	CheckedBy      string     `json:"checked_by"`
+	CheckedAt      *time.Time `json:"checked_at"`
+	Version        uint       `gorm:"not null;default:1" json:"version"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// User is a local account that can sign in to the API
+type User struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Username     string     `gorm:"unique;not null;index" json:"username"`
+	DisplayName  string     `json:"display_name"`
+	PasswordHash string     `gorm:"not null" json:"-"`
+	Disabled     bool       `gorm:"default:false" json:"disabled"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+	// Tokens issued before the password last changed are rejected
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	Version           uint       `gorm:"not null;default:1" json:"version"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Name returns the display name, falling back to the username
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
 }
 
-// TableName overrides the table name for Library
-func (Library) TableName() string {
-	return "library"
+// Permission grants a role to a user, optionally scoped to a project
+// and/or an environment by name. Nil/empty scope means all.
+type Permission struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	Role        string    `gorm:"not null" json:"role"`
+	ProjectID   *uint     `gorm:"index" json:"project_id"`
+	Environment string    `json:"environment"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AuditEvent records a single mutation of an entity
+type AuditEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Timestamp  time.Time `gorm:"index" json:"timestamp"`
+	Actor      string    `gorm:"index" json:"actor"`
+	RequestID  string    `gorm:"index" json:"request_id"`
+	EntityType string    `gorm:"not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Action     string    `gorm:"not null" json:"action"`
+	Changes    AuditDiff `gorm:"type:json" json:"changes"`
+}
+
+// Developer is a person that components and deployments are assigned to
+type Developer struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"unique;not null" json:"name"`
+	Email     string    `json:"email"`
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Server is a host used to build and/or deploy projects
+type Server struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"unique;not null" json:"name"`
+	Hostname    string    `json:"hostname"`
+	IPAddress   string    `json:"ip_address"`
+	OS          string    `json:"os"`
+	Owner       string    `json:"owner"`
+	Build       bool      `json:"build"`  // offered as a build server
+	Deploy      bool      `json:"deploy"` // offered as a deploy server
+	Description string    `gorm:"type:text" json:"description"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Environment is a deployment stage. Environments are listed by Position.
+type Environment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"unique;not null" json:"name"`
+	Position    int       `json:"position"`
+	Description string    `gorm:"type:text" json:"description"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Library is the list of preset names offered by the dropdowns, read from
+// and written to the developer, server and environment tables
+type Library struct {
+	Developers    StringArray `json:"developers"`
+	BuildServers  StringArray `json:"build_servers"`
+	DeployServers StringArray `json:"deploy_servers"`
+	Environments  StringArray `json:"environments"`
 }
 
 // Settings stores application settings (singleton)
@@ -126,6 +266,7 @@ type Settings struct {
 	DefaultDeployedBy  string    `json:"default_deployed_by"`
 	ExcelExportPath    string    `json:"excel_export_path"`
 	AutoClearAfterSave bool      `json:"auto_clear_after_save"`
+	Version            uint      `gorm:"not null;default:1" json:"version"`
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
 }
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchEnabled reports whether the FTS5 search index is available.
+// FTS5 requires building with the sqlite_fts5 tag.
+var SearchEnabled bool
+
+// searchSchema creates the FTS5 table and the triggers that keep it in sync.
+// Rowids interleave entity types so rows can be addressed directly:
+// deployment = id*3, project = id*3+1, component = id*3+2.
+var searchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		entity_type UNINDEXED,
+		entity_id UNINDEXED,
+		title,
+		jira_id,
+		notes,
+		database_script,
+		vcs_url,
+		description,
+		tokenize = 'unicode61'
+	)`,
+
+	// Deployments
+	`CREATE TRIGGER IF NOT EXISTS deployments_search_ai AFTER INSERT ON deployments BEGIN
+		INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+		VALUES (new.id*3, 'deployment', new.id, new.jira_id, new.jira_id, new.notes, new.database_script, new.vcs_url, '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS deployments_search_ad AFTER DELETE ON deployments BEGIN
+		DELETE FROM search_index WHERE rowid = old.id*3;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS deployments_search_au AFTER UPDATE ON deployments BEGIN
+		DELETE FROM search_index WHERE rowid = old.id*3;
+		INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+		VALUES (new.id*3, 'deployment', new.id, new.jira_id, new.jira_id, new.notes, new.database_script, new.vcs_url, '');
+	END`,
+
+	// Projects
+	`CREATE TRIGGER IF NOT EXISTS projects_search_ai AFTER INSERT ON projects BEGIN
+		INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+		VALUES (new.id*3+1, 'project', new.id, new.name, '', '', '', '', new.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS projects_search_ad AFTER DELETE ON projects BEGIN
+		DELETE FROM search_index WHERE rowid = old.id*3+1;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS projects_search_au AFTER UPDATE ON projects BEGIN
+		DELETE FROM search_index WHERE rowid = old.id*3+1;
+		INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+		VALUES (new.id*3+1, 'project', new.id, new.name, '', '', '', '', new.description);
+	END`,
+
+	// Components
+	`CREATE TRIGGER IF NOT EXISTS components_search_ai AFTER INSERT ON components BEGIN
+		INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+		VALUES (new.id*3+2, 'component', new.id, new.name, '', '', '', new.vcs_url, new.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS components_search_ad AFTER DELETE ON components BEGIN
+		DELETE FROM search_index WHERE rowid = old.id*3+2;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS components_search_au AFTER UPDATE ON components BEGIN
+		DELETE FROM search_index WHERE rowid = old.id*3+2;
+		INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+		VALUES (new.id*3+2, 'component', new.id, new.name, '', '', '', new.vcs_url, new.description);
+	END`,
+}
+
+// searchTriggers are the triggers searchSchema creates. They write to the
+// FTS5 table, so a binary built without FTS5 must drop them before it can
+// write to the tables they watch.
+var searchTriggers = []string{
+	"deployments_search_ai", "deployments_search_ad", "deployments_search_au",
+	"projects_search_ai", "projects_search_ad", "projects_search_au",
+	"components_search_ai", "components_search_ad", "components_search_au",
+}
+
+// errSearchUnavailable is returned when SQLite was built without FTS5
+var errSearchUnavailable = errors.New("full-text search unavailable (build with -tags sqlite_fts5)")
+
+// fts5Available reports whether the SQLite library provides FTS5
+func fts5Available(db *gorm.DB) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT count(*) FROM pragma_module_list WHERE name = 'fts5'").Scan(&count).Error
+	return count > 0, err
+}
+
+// dropStaleSearchTriggers drops the search triggers when FTS5 is missing.
+// A database last opened by an FTS5 build would otherwise fail every write
+// to deployments, projects and components with "no such module: fts5".
+func dropStaleSearchTriggers() error {
+	available, err := fts5Available(DB)
+	if err != nil || available {
+		return err
+	}
+
+	for _, trigger := range searchTriggers {
+		if err := DB.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+			return fmt.Errorf("failed to drop search trigger %s: %w", trigger, err)
+		}
+	}
+	return nil
+}
+
+// searchMigration is the migration that owns the search index
+const searchMigration = 12
+
+// searchIndexUp creates the full-text index and its sync triggers when
+// SQLite provides FTS5. Without FTS5 nothing is created; InitSearchIndex
+// creates the index once an FTS5 build opens the database.
+func searchIndexUp(tx *gorm.DB) error {
+	available, err := fts5Available(tx)
+	if err != nil {
+		return err
+	}
+	if !available {
+		log.Printf("⚠️  Warning: %v; the search index will be created by an FTS5 build", errSearchUnavailable)
+		return nil
+	}
+	return createSearchIndex(tx)
+}
+
+// searchIndexDown drops the search triggers and index. Without FTS5 the
+// virtual table cannot be dropped and is left behind unused.
+func searchIndexDown(tx *gorm.DB) error {
+	for _, trigger := range searchTriggers {
+		if err := tx.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+			return err
+		}
+	}
+
+	available, err := fts5Available(tx)
+	if err != nil {
+		return err
+	}
+	if !available {
+		log.Printf("⚠️  Warning: %v; leaving the search_index table in place", errSearchUnavailable)
+		return nil
+	}
+	return tx.Exec("DROP TABLE IF EXISTS search_index").Error
+}
+
+// createSearchIndex creates the index and triggers that are missing and
+// rebuilds the index from existing rows when it is new or its triggers were
+// missing, e.g. after a build without FTS5 dropped them
+func createSearchIndex(tx *gorm.DB) error {
+	var existing int64
+	err := tx.Raw("SELECT count(*) FROM sqlite_master WHERE (type = 'table' AND name = 'search_index') OR (type = 'trigger' AND name IN ?)", searchTriggers).
+		Scan(&existing).Error
+	if err != nil {
+		return err
+	}
+	if existing == int64(len(searchTriggers)+1) {
+		return nil
+	}
+
+	for _, statement := range searchSchema {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create search index: %w", err)
+		}
+	}
+	if err := RebuildSearchIndex(tx); err != nil {
+		return err
+	}
+	log.Println("✅ Full-text search index built")
+	return nil
+}
+
+// InitSearchIndex enables search when SQLite provides FTS5 and the search
+// index migration is applied, restoring the index if a build without FTS5
+// applied the migration or dropped the triggers
+func InitSearchIndex() error {
+	SearchEnabled = false
+
+	available, err := fts5Available(DB)
+	if err != nil {
+		return err
+	}
+	if !available {
+		if err := dropStaleSearchTriggers(); err != nil {
+			return err
+		}
+		return errSearchUnavailable
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+	if _, ok := applied[searchMigration]; !ok {
+		return fmt.Errorf("full-text search unavailable: migration %03d is not applied", searchMigration)
+	}
+
+	if err := DB.Transaction(createSearchIndex); err != nil {
+		return err
+	}
+	SearchEnabled = true
+	return nil
+}
+
+// RebuildSearchIndex repopulates the full-text index from the source tables
+func RebuildSearchIndex(db *gorm.DB) error {
+	statements := []string{
+		`DELETE FROM search_index`,
+		`INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+			SELECT id*3, 'deployment', id, jira_id, jira_id, notes, database_script, vcs_url, '' FROM deployments`,
+		`INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+			SELECT id*3+1, 'project', id, name, '', '', '', '', description FROM projects`,
+		`INSERT INTO search_index(rowid, entity_type, entity_id, title, jira_id, notes, database_script, vcs_url, description)
+			SELECT id*3+2, 'component', id, name, '', '', '', vcs_url, description FROM components`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SearchHit is a single ranked full-text search result
+type SearchHit struct {
+	EntityType string  `json:"entity_type"`
+	EntityID   uint    `json:"entity_id"`
+	Title      string  `json:"title"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+// Snippets mark matches with the STX and ETX control characters. The text
+// is HTML-escaped as they become <mark> tags, so stored notes and scripts
+// cannot inject markup into a snippet that is rendered as HTML.
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// snippetMarkup escapes snippet text and turns the match markers into tags
+var snippetMarkup = strings.NewReplacer(
+	snippetOpen, "<mark>",
+	snippetClose, "</mark>",
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+// SearchEntityTypes lists the entity types stored in the search index
+var SearchEntityTypes = []string{"deployment", "project", "component"}
+
+// Search runs a ranked full-text query. Every term in q must match;
+// terms are matched as prefixes and FTS5 operators in q are treated as text.
+func Search(q string, entityTypes []string, limit int) ([]SearchHit, error) {
+	match := buildMatchQuery(q)
+	if match == "" {
+		return []SearchHit{}, nil
+	}
+
+	// Column weights: title, jira_id, notes, database_script, vcs_url, description
+	query := DB.Table("search_index").
+		Select(`entity_type, entity_id, title,
+			snippet(search_index, -1, char(2), char(3), '…', 16) AS snippet,
+			bm25(search_index, 0, 0, 10.0, 8.0, 2.0, 1.0, 2.0, 2.0) AS score`).
+		Where("search_index MATCH ?", match)
+
+	if len(entityTypes) > 0 {
+		query = query.Where("entity_type IN ?", entityTypes)
+	}
+
+	hits := []SearchHit{}
+	if err := query.Order("score ASC").Limit(limit).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		hits[i].Snippet = snippetMarkup.Replace(hits[i].Snippet)
+	}
+	return hits, nil
+}
+
+// buildMatchQuery turns free text into an FTS5 query of quoted prefix terms
+func buildMatchQuery(q string) string {
+	var terms []string
+	for _, term := range strings.Fields(q) {
+		term = strings.ReplaceAll(term, `"`, `""`)
+		terms = append(terms, `"`+term+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SnapshotTo writes a transactionally consistent copy of the live database
+// to path using VACUUM INTO, so concurrent writers and un-checkpointed WAL
+// pages are handled by SQLite rather than by copying the file underneath it.
+// The target file must not exist yet.
+func SnapshotTo(path string) error {
+	if err := DB.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// CheckIntegrity opens the SQLite file at path read-only and runs
+// PRAGMA integrity_check against it
+func CheckIntegrity(path string) error {
+	conn, closeConn, err := openReadOnly(path)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	var results []string
+	if err := conn.Raw("PRAGMA integrity_check").Scan(&results).Error; err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+
+	if len(results) != 1 || results[0] != "ok" {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(results, "; "))
+	}
+
+	return nil
+}
+
+// SchemaVersionOf reports the schema version recorded in the SQLite file at
+// path. Databases from before versioned migrations report 0; files that are
+// not chklst databases at all are rejected.
+func SchemaVersionOf(path string) (int, error) {
+	conn, closeConn, err := openReadOnly(path)
+	if err != nil {
+		return 0, err
+	}
+	defer closeConn()
+
+	var tables []string
+	if err := conn.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name IN ('schema_migrations', 'projects')").Scan(&tables).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	hasMigrations, hasProjects := false, false
+	for _, table := range tables {
+		switch table {
+		case "schema_migrations":
+			hasMigrations = true
+		case "projects":
+			hasProjects = true
+		}
+	}
+
+	if !hasMigrations {
+		if !hasProjects {
+			return 0, errors.New("not a chklst database")
+		}
+		return 0, nil
+	}
+
+	var version int
+	if err := conn.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// RowCountsOf counts the rows of every application table in the SQLite file
+// at path. SQLite internals and the rebuildable search index are skipped.
+func RowCountsOf(path string) (map[string]int64, error) {
+	conn, closeConn, err := openReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConn()
+
+	var tables []string
+	if err := conn.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT LIKE 'search_index%' ORDER BY name").Scan(&tables).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var count int64
+		if err := conn.Table(table).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		counts[table] = count
+	}
+
+	return counts, nil
+}
+
+// HealthProbe verifies that the open database answers queries, is at the
+// schema version this binary expects and passes a quick consistency check
+func HealthProbe() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database instance: %w", err)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version != LatestSchemaVersion() {
+		return fmt.Errorf("database schema is at version %d, expected %d", version, LatestSchemaVersion())
+	}
+
+	var results []string
+	if err := DB.Raw("PRAGMA quick_check").Scan(&results).Error; err != nil {
+		return fmt.Errorf("failed to run quick check: %w", err)
+	}
+	if len(results) != 1 || results[0] != "ok" {
+		return fmt.Errorf("quick check failed: %s", strings.Join(results, "; "))
+	}
+
+	return nil
+}
+
+// openReadOnly opens a standalone read-only connection to the SQLite file at
+// path, independent of the application's connection pool
+func openReadOnly(path string) (*gorm.DB, func(), error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	conn, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+
+	return conn, func() { sqlDB.Close() }, nil
+}
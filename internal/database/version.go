@@ -0,0 +1,39 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rows carry a version that every update increments. Clients send the
+// version they read back in If-Match, and SaveVersion only writes when the
+// stored version still matches, so concurrent edits fail instead of
+// silently overwriting each other.
+
+// ErrVersionConflict is returned when a row changed since it was read
+var ErrVersionConflict = errors.New("record was modified by another request")
+
+// incrementVersion bumps the version column in bulk updates
+var incrementVersion = gorm.Expr("version + 1")
+
+// SaveVersion writes every column of model, a pointer to a loaded row, if
+// its stored version is still expected, and sets *version to the new
+// version. It returns ErrVersionConflict when the row changed or is gone.
+// Associations are not saved.
+func SaveVersion(tx *gorm.DB, model interface{}, version *uint, expected uint) error {
+	*version = expected + 1
+
+	result := tx.Model(model).Where("version = ?", expected).
+		Select("*").Omit("created_at", clause.Associations).Updates(model)
+	if result.Error != nil {
+		*version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		*version = expected
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSaveVersion(t *testing.T) {
+	migrateTestDB(t)
+	project := Project{Name: "billing", Version: 1}
+	if err := DB.Create(&project).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		id       uint
+		expected uint
+		err      error
+		version  uint
+	}{
+		{"current version", project.ID, 1, nil, 2},
+		{"stale version", project.ID, 1, ErrVersionConflict, 1},
+		{"future version", project.ID, 5, ErrVersionConflict, 5},
+		{"next version", project.ID, 2, nil, 3},
+		{"missing row", 99, 1, ErrVersionConflict, 1},
+	}
+
+	for _, tt := range tests {
+		update := Project{ID: tt.id, Name: tt.name}
+		err := SaveVersion(DB, &update, &update.Version, tt.expected)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: SaveVersion = %v, want %v", tt.name, err, tt.err)
+		}
+		if update.Version != tt.version {
+			t.Fatalf("%s: version = %d, want %d", tt.name, update.Version, tt.version)
+		}
+	}
+
+	// Only the two saves that expected the stored version were written
+	var stored Project
+	DB.First(&stored, project.ID)
+	if stored.Name != "next version" || stored.Version != 3 {
+		t.Fatalf("stored project = %q at version %d, want %q at version 3", stored.Name, stored.Version, "next version")
+	}
+	if !stored.CreatedAt.Equal(project.CreatedAt) {
+		t.Fatalf("created_at changed from %v to %v", project.CreatedAt, stored.CreatedAt)
+	}
+}
@@ -0,0 +1,457 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"chklst-go/internal/config"
+	"chklst-go/internal/database"
+)
+
+// Backup archive layout
+const (
+	archiveExt    = ".tar.gz"
+	manifestEntry = "manifest.json"
+	databaseEntry = "chklst.db"
+	settingsEntry = "settings.json"
+)
+
+// Backup kinds reported by ListBackups
+const (
+	BackupKindArchive  = "archive"
+	BackupKindDatabase = "database"
+	BackupKindSettings = "settings"
+)
+
+// BackupManifest describes the contents of a backup archive
+type BackupManifest struct {
+	CreatedAt     time.Time               `json:"created_at"`
+	AppVersion    string                  `json:"app_version"`
+	SchemaVersion int                     `json:"schema_version"`
+	Encryption    *ManifestEncryption     `json:"encryption,omitempty"`
+	Files         map[string]ManifestFile `json:"files"`
+	RowCounts     map[string]int64        `json:"row_counts"`
+}
+
+// ManifestEncryption records how the entries of an archive were encrypted
+type ManifestEncryption struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+}
+
+// ManifestFile records the size and SHA-256 checksum of one archive entry
+type ManifestFile struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BackupInfo describes one file in the backup directory
+type BackupInfo struct {
+	Name       string          `json:"name"`
+	Kind       string          `json:"kind"`
+	Size       int64           `json:"size"`
+	ModifiedAt time.Time       `json:"modified_at"`
+	Encrypted  bool            `json:"encrypted"`
+	Pinned     bool            `json:"pinned"`
+	KeyID      string          `json:"key_id,omitempty"`
+	Manifest   *BackupManifest `json:"manifest,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// FileCheck is the checksum comparison for one archive entry
+type FileCheck struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	OK       bool   `json:"ok"`
+}
+
+// BackupVerification is the result of re-checking an archive's checksums
+type BackupVerification struct {
+	Name     string          `json:"name"`
+	Valid    bool            `json:"valid"`
+	Manifest *BackupManifest `json:"manifest,omitempty"`
+	Files    []FileCheck     `json:"files"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// ErrBackupNotFound is returned when a named backup does not exist
+var ErrBackupNotFound = errors.New("backup not found")
+
+// backupKind classifies a backup directory entry by its extension
+func backupKind(name string) string {
+	name = strings.TrimSuffix(name, encryptedExt)
+
+	switch {
+	case strings.HasSuffix(name, archiveExt):
+		return BackupKindArchive
+	case filepath.Ext(name) == ".db":
+		return BackupKindDatabase
+	case filepath.Ext(name) == ".json":
+		return BackupKindSettings
+	default:
+		return ""
+	}
+}
+
+// BackupPath resolves a backup name to a file in the backup directory.
+// Names may not contain path separators.
+func (bm *BackupManager) BackupPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || backupKind(name) == "" {
+		return "", fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+
+	path := filepath.Join(bm.backupDir, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+
+	return path, nil
+}
+
+// writeArchive snapshots the database and settings into a staging directory,
+// writes the manifest and packs all three into a gzip-compressed tarball
+func (bm *BackupManager) writeArchive(archivePath string) (*BackupManifest, error) {
+	stagingDir, err := os.MkdirTemp(bm.backupDir, ".staging-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	dbFile := filepath.Join(stagingDir, databaseEntry)
+	if err := bm.snapshot(dbFile); err != nil {
+		return nil, err
+	}
+
+	settingsFile := filepath.Join(stagingDir, settingsEntry)
+	if err := writeSettingsFile(settingsFile); err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{
+		CreatedAt:  time.Now().UTC(),
+		AppVersion: config.Version,
+		Files:      make(map[string]ManifestFile),
+	}
+	if manifest.SchemaVersion, err = database.SchemaVersionOf(dbFile); err != nil {
+		return nil, err
+	}
+	if manifest.RowCounts, err = database.RowCountsOf(dbFile); err != nil {
+		return nil, err
+	}
+
+	// Entries are encrypted individually so the manifest stays readable
+	entries := []string{databaseEntry, settingsEntry}
+	if bm.keyring != nil {
+		manifest.Encryption = &ManifestEncryption{
+			Algorithm: EncryptionAlgorithm,
+			KeyID:     bm.keyring.ActiveKeyID(),
+		}
+		for i, entry := range entries {
+			entries[i] = entry + encryptedExt
+			if err := bm.sealFile(filepath.Join(stagingDir, entry), filepath.Join(stagingDir, entries[i])); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		file, err := checksumFile(filepath.Join(stagingDir, entry))
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", entry, err)
+		}
+		manifest.Files[entry] = file
+	}
+
+	manifestFile := filepath.Join(stagingDir, manifestEntry)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(manifestFile, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	// The manifest goes first so listing only has to read the archive's head
+	tmpPath := archivePath + ".tmp"
+	if err := packArchive(tmpPath, stagingDir, append([]string{manifestEntry}, entries...)); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to finalize backup: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// packArchive writes the named files from dir into a gzip-compressed tarball
+func packArchive(archivePath string, dir string, entries []string) error {
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		if err := addToArchive(tw, filepath.Join(dir, entry), entry); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", entry, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	return out.Sync()
+}
+
+// addToArchive appends one file to a tar stream under the given name
+func addToArchive(tw *tar.Writer, path string, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// openArchive opens a backup archive for sequential reading
+func openArchive(archivePath string) (*tar.Reader, func(), error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+
+	return tar.NewReader(gz), func() {
+		gz.Close()
+		file.Close()
+	}, nil
+}
+
+// readManifest reads and decodes the manifest entry of a backup archive
+func readManifest(archivePath string) (*BackupManifest, error) {
+	tr, closeArchive, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("archive has no manifest")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Name != manifestEntry {
+			continue
+		}
+
+		var manifest BackupManifest
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %w", err)
+		}
+		return &manifest, nil
+	}
+}
+
+// VerifyBackup re-computes the checksum of every entry in a backup archive
+// and compares it with the manifest
+func (bm *BackupManager) VerifyBackup(name string) (*BackupVerification, error) {
+	archivePath, err := bm.BackupPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if backupKind(name) != BackupKindArchive {
+		return nil, fmt.Errorf("%s is not a backup archive", name)
+	}
+
+	result := &BackupVerification{Name: name, Files: []FileCheck{}}
+
+	manifest, err := readManifest(archivePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Manifest = manifest
+
+	actual, err := checksumArchive(archivePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	entries := make([]string, 0, len(manifest.Files))
+	for entry := range manifest.Files {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+
+	result.Valid = len(entries) > 0
+	for _, entry := range entries {
+		check := FileCheck{
+			Name:     entry,
+			Expected: manifest.Files[entry].SHA256,
+			Actual:   actual[entry],
+		}
+		check.OK = check.Actual != "" && check.Actual == check.Expected
+		if !check.OK {
+			result.Valid = false
+		}
+		result.Files = append(result.Files, check)
+	}
+
+	return result, nil
+}
+
+// checksumArchive hashes every entry of a backup archive except the manifest
+func checksumArchive(archivePath string) (map[string]string, error) {
+	tr, closeArchive, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	sums := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return sums, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Name == manifestEntry {
+			continue
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, tr); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		sums[header.Name] = hex.EncodeToString(hash.Sum(nil))
+	}
+}
+
+// extractDatabase unpacks the database from a backup archive into a
+// temporary file, rejecting it if its checksum does not match the manifest
+// and decrypting it if needed. The caller removes the returned file.
+func (bm *BackupManager) extractDatabase(archivePath string) (string, error) {
+	manifest, err := readManifest(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	dbEntry := databaseEntry
+	if manifest.Encryption != nil {
+		dbEntry += encryptedExt
+	}
+	expected, ok := manifest.Files[dbEntry]
+	if !ok {
+		return "", fmt.Errorf("%w: manifest does not list %s", ErrInvalidBackup, dbEntry)
+	}
+
+	tr, closeArchive, err := openArchive(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer closeArchive()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("%w: archive has no %s", ErrInvalidBackup, dbEntry)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to read archive: %v", ErrInvalidBackup, err)
+		}
+		if header.Name != dbEntry {
+			continue
+		}
+
+		out, err := os.CreateTemp(bm.backupDir, ".extract-*.db")
+		if err != nil {
+			return "", fmt.Errorf("failed to extract database: %w", err)
+		}
+
+		hash := sha256.New()
+		_, copyErr := io.Copy(io.MultiWriter(out, hash), tr)
+		closeErr := out.Close()
+		if copyErr != nil || closeErr != nil {
+			os.Remove(out.Name())
+			return "", fmt.Errorf("failed to extract database: %w", errors.Join(copyErr, closeErr))
+		}
+
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != expected.SHA256 {
+			os.Remove(out.Name())
+			return "", fmt.Errorf("%w: %s checksum mismatch", ErrInvalidBackup, dbEntry)
+		}
+
+		if manifest.Encryption == nil {
+			return out.Name(), nil
+		}
+
+		defer os.Remove(out.Name())
+		return bm.decryptToTemp(out.Name())
+	}
+}
+
+// checksumFile returns the size and SHA-256 of a file
+func checksumFile(path string) (ManifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	return ManifestFile{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
@@ -2,22 +2,49 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"chklst-go/internal/database"
+
+	"gorm.io/gorm"
 )
 
 // BackupManager handles database and settings backups
 type BackupManager struct {
 	backupDir string
+	keyring   *Keyring
+	retention RetentionPolicy
+	targets   []BackupTarget
+	mu        sync.Mutex
+}
+
+// BackupOptions configures a BackupManager
+type BackupOptions struct {
+	// Keyring encrypts archives, settings exports and pre-restore snapshots
+	Keyring *Keyring
+	// Retention is applied by the scheduled prune job and POST /backups/prune
+	Retention RetentionPolicy
+	// Targets receive a copy of every backup archive
+	Targets []BackupTarget
+}
+
+// BackupResult describes a finished backup
+type BackupResult struct {
+	Path     string          `json:"backup_path"`
+	Manifest *BackupManifest `json:"manifest"`
+	Uploads  []UploadResult  `json:"uploads"`
 }
 
 // NewBackupManager creates a new backup manager
-func NewBackupManager(backupDir string) *BackupManager {
+func NewBackupManager(backupDir string, opts BackupOptions) *BackupManager {
 	// Ensure backup directory exists
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
 		AppLogger.Error("Failed to create backup directory", err, map[string]interface{}{
@@ -27,114 +54,357 @@ func NewBackupManager(backupDir string) *BackupManager {
 
 	return &BackupManager{
 		backupDir: backupDir,
+		keyring:   opts.Keyring,
+		retention: opts.Retention,
+		targets:   opts.Targets,
 	}
 }
 
-// BackupDatabase creates a backup of the SQLite database
-func (bm *BackupManager) BackupDatabase(dbPath string) (string, error) {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	backupFileName := fmt.Sprintf("chklst_backup_%s.db", timestamp)
-	backupPath := filepath.Join(bm.backupDir, backupFileName)
-
-	// Open source database file
-	sourceFile, err := os.Open(dbPath)
+// BackupDatabase creates a compressed backup archive holding a consistent
+// online snapshot of the database, a settings export and a manifest. The
+// snapshot must pass an integrity check and the archive is only renamed into
+// place once complete, so a failed backup never shows up in the backup
+// directory. The archive is then uploaded to every target; upload failures
+// are reported in the result but do not fail the backup.
+func (bm *BackupManager) BackupDatabase() (*BackupResult, error) {
+	result, err := bm.writeBackup()
 	if err != nil {
-		return "", fmt.Errorf("failed to open source database: %w", err)
+		return nil, err
 	}
-	defer sourceFile.Close()
 
-	// Create backup file
-	destFile, err := os.Create(backupPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create backup file: %w", err)
-	}
-	defer destFile.Close()
+	result.Uploads = bm.upload(result.Path)
 
-	// Copy database file
-	_, err = io.Copy(destFile, sourceFile)
+	return result, nil
+}
+
+// writeBackup writes a new backup archive to the backup directory
+func (bm *BackupManager) writeBackup() (*BackupResult, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	backupFileName := bm.timestampedName("chklst_backup", archiveExt)
+	backupPath := filepath.Join(bm.backupDir, backupFileName)
+
+	manifest, err := bm.writeArchive(backupPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to copy database: %w", err)
+		return nil, err
 	}
 
 	AppLogger.Info("Database backed up successfully", map[string]interface{}{
-		"backup_file": backupFileName,
-		"backup_path": backupPath,
+		"backup_file":    backupFileName,
+		"backup_path":    backupPath,
+		"schema_version": manifest.SchemaVersion,
 	})
 
-	return backupPath, nil
+	return &BackupResult{Path: backupPath, Manifest: manifest, Uploads: []UploadResult{}}, nil
+}
+
+// timestampedName returns a file name in the backup directory stamped with
+// the current time, suffixed with a counter if that second is already taken
+func (bm *BackupManager) timestampedName(prefix string, ext string) string {
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	name := fmt.Sprintf("%s_%s%s", prefix, timestamp, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(bm.backupDir, name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s_%s_%d%s", prefix, timestamp, i, ext)
+	}
+}
+
+// snapshot writes a verified copy of the live database to path
+func (bm *BackupManager) snapshot(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file already exists: %s", path)
+	}
+
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+
+	if err := database.SnapshotTo(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := database.CheckIntegrity(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		AppLogger.Error("Backup rejected", err, map[string]interface{}{
+			"backup_path": path,
+		})
+		return fmt.Errorf("backup rejected: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to finalize backup: %w", err)
+	}
+
+	return nil
 }
 
-// RestoreDatabase restores database from backup
+// ErrInvalidBackup is returned when a backup fails validation before restore
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// RestoreDatabase replaces the live database with a backup archive or a raw
+// database file. Archives are unpacked and checked against their manifest
+// checksum first. The database must pass an integrity check and carry a schema this binary understands. The
+// current database is snapshotted first, the connection pool is closed while
+// the file is swapped in with an atomic rename, and if the reopened database
+// fails its health probe the snapshot is swapped back. Callers are expected
+// to hold off other requests while this runs.
 func (bm *BackupManager) RestoreDatabase(backupPath string, targetPath string) error {
-	// Verify backup file exists
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-		return fmt.Errorf("backup file does not exist: %s", backupPath)
-	}
-
-	// Create a backup of current database before restoring
-	currentBackupName := fmt.Sprintf("pre_restore_%s.db", time.Now().Format("2006-01-02_15-04-05"))
-	currentBackupPath := filepath.Join(bm.backupDir, currentBackupName)
-
-	if _, err := os.Stat(targetPath); err == nil {
-		// Current database exists, back it up first
-		currentFile, err := os.Open(targetPath)
-		if err == nil {
-			defer currentFile.Close()
-			backupFile, err := os.Create(currentBackupPath)
-			if err == nil {
-				defer backupFile.Close()
-				io.Copy(backupFile, currentFile)
-				AppLogger.Info("Current database backed up before restore", map[string]interface{}{
-					"backup_file": currentBackupName,
-				})
-			}
+		return fmt.Errorf("%w: backup file does not exist: %s", ErrInvalidBackup, backupPath)
+	}
+
+	if backupKind(backupPath) == BackupKindArchive {
+		extracted, err := bm.extractDatabase(backupPath)
+		if err != nil {
+			return err
 		}
+		defer os.Remove(extracted)
+
+		return bm.restoreFile(extracted, backupPath, targetPath)
 	}
 
-	// Open backup file
-	sourceFile, err := os.Open(backupPath)
+	encrypted, err := IsEncryptedFile(backupPath)
 	if err != nil {
-		return fmt.Errorf("failed to open backup file: %w", err)
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
 	}
-	defer sourceFile.Close()
+	if encrypted {
+		decrypted, err := bm.decryptToTemp(backupPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(decrypted)
 
-	// Create/overwrite target database
-	destFile, err := os.Create(targetPath)
-	if err != nil {
-		return fmt.Errorf("failed to create target database: %w", err)
+		return bm.restoreFile(decrypted, backupPath, targetPath)
 	}
-	defer destFile.Close()
 
-	// Copy backup to target
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return fmt.Errorf("failed to restore database: %w", err)
+	return bm.restoreFile(backupPath, backupPath, targetPath)
+}
+
+// restoreFile validates a raw database file and swaps it in for targetPath.
+// source names the backup the file came from, for logging.
+func (bm *BackupManager) restoreFile(backupPath string, source string, targetPath string) error {
+	if err := validateBackup(backupPath); err != nil {
+		return err
+	}
+
+	// Stage the backup next to the target so the swap is a same-directory rename
+	stagingPath := targetPath + ".restore"
+	if err := copyFile(backupPath, stagingPath); err != nil {
+		return fmt.Errorf("failed to stage backup: %w", err)
+	}
+	defer os.Remove(stagingPath)
+
+	// Snapshot the current database so a failed restore can be rolled back
+	preRestoreName := bm.timestampedName("pre_restore", bm.fileExt(".db"))
+	preRestorePath := filepath.Join(bm.backupDir, preRestoreName)
+	if err := bm.saveSnapshot(preRestorePath); err != nil {
+		return fmt.Errorf("failed to back up current database: %w", err)
+	}
+	AppLogger.Info("Current database backed up before restore", map[string]interface{}{
+		"backup_file": preRestoreName,
+	})
+
+	if err := swapDatabase(stagingPath, targetPath); err != nil {
+		AppLogger.Error("Restored database failed health probe, rolling back", err, map[string]interface{}{
+			"from":     source,
+			"snapshot": preRestoreName,
+		})
+
+		rollbackErr := bm.copyDecrypted(preRestorePath, stagingPath)
+		if rollbackErr == nil {
+			rollbackErr = swapDatabase(stagingPath, targetPath)
+		}
+		if rollbackErr != nil {
+			return fmt.Errorf("restore failed (%v) and rollback to %s failed: %w", err, preRestoreName, rollbackErr)
+		}
+
+		return fmt.Errorf("restore failed, rolled back to %s: %w", preRestoreName, err)
 	}
 
 	AppLogger.Info("Database restored successfully", map[string]interface{}{
-		"from": backupPath,
+		"from": source,
 		"to":   targetPath,
 	})
 
 	return nil
 }
 
+// saveSnapshot writes a verified snapshot of the live database to path,
+// encrypted when a backup key is configured
+func (bm *BackupManager) saveSnapshot(path string) error {
+	plainPath := path + ".plain"
+	if err := bm.snapshot(plainPath); err != nil {
+		return err
+	}
+
+	return bm.sealFile(plainPath, path)
+}
+
+// sealFile moves plainPath to path, encrypting it on the way when a backup
+// key is configured. plainPath is removed either way.
+func (bm *BackupManager) sealFile(plainPath string, path string) error {
+	defer os.Remove(plainPath)
+
+	if bm.keyring == nil {
+		return os.Rename(plainPath, path)
+	}
+
+	if err := bm.keyring.EncryptFile(plainPath, path); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to encrypt %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+// fileExt appends the encrypted suffix to ext when a backup key is configured
+func (bm *BackupManager) fileExt(ext string) string {
+	if bm.keyring == nil {
+		return ext
+	}
+	return ext + encryptedExt
+}
+
+// decryptToTemp decrypts an encrypted backup file into a temporary file in
+// the backup directory. The caller removes the returned file.
+func (bm *BackupManager) decryptToTemp(path string) (string, error) {
+	out, err := os.CreateTemp(bm.backupDir, ".decrypt-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	out.Close()
+
+	if err := bm.keyring.DecryptFile(path, out.Name()); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	return out.Name(), nil
+}
+
+// copyDecrypted copies src to dst, decrypting it if it is encrypted
+func (bm *BackupManager) copyDecrypted(src string, dst string) error {
+	encrypted, err := IsEncryptedFile(src)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return bm.keyring.DecryptFile(src, dst)
+	}
+
+	return copyFile(src, dst)
+}
+
+// validateBackup checks that a backup file is intact and was written by a
+// schema no newer than this binary's
+func validateBackup(backupPath string) error {
+	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+		return fmt.Errorf("%w: backup file does not exist: %s", ErrInvalidBackup, backupPath)
+	}
+
+	if err := database.CheckIntegrity(backupPath); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	version, err := database.SchemaVersionOf(backupPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if version > database.LatestSchemaVersion() {
+		return fmt.Errorf("%w: schema version %d is newer than supported version %d", ErrInvalidBackup, version, database.LatestSchemaVersion())
+	}
+
+	return nil
+}
+
+// swapDatabase closes the connection pool, renames stagingPath over
+// targetPath and reopens it. Older schemas are migrated forward before the
+// health probe runs.
+func swapDatabase(stagingPath string, targetPath string) error {
+	if err := database.CloseDatabase(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	// Stale journal files belong to the old database and must not be replayed
+	os.Remove(targetPath + "-wal")
+	os.Remove(targetPath + "-shm")
+
+	renameErr := os.Rename(stagingPath, targetPath)
+
+	if err := database.InitDatabase(targetPath); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to swap database file: %w", renameErr)
+	}
+
+	if err := database.Migrate(true); err != nil {
+		return err
+	}
+
+	return database.HealthProbe()
+}
+
+// copyFile copies src to dst and syncs it to disk
+func copyFile(src string, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, sourceFile); err != nil {
+		return err
+	}
+
+	return destFile.Sync()
+}
+
 // ExportSettings exports library/settings to JSON
 func (bm *BackupManager) ExportSettings() (string, error) {
-	// Get library from database
-	var library database.Library
-	if err := database.DB.First(&library).Error; err != nil {
-		return "", fmt.Errorf("failed to get library: %w", err)
+	fileName := bm.timestampedName("settings_export", bm.fileExt(".json"))
+	filePath := filepath.Join(bm.backupDir, fileName)
+
+	plainPath := filePath + ".plain"
+	if err := writeSettingsFile(plainPath); err != nil {
+		os.Remove(plainPath)
+		return "", err
+	}
+	if err := bm.sealFile(plainPath, filePath); err != nil {
+		return "", err
 	}
 
-	// Create JSON file
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	fileName := fmt.Sprintf("settings_export_%s.json", timestamp)
-	filePath := filepath.Join(bm.backupDir, fileName)
+	AppLogger.Info("Settings exported successfully", map[string]interface{}{
+		"file": fileName,
+		"path": filePath,
+	})
+
+	return filePath, nil
+}
 
-	file, err := os.Create(filePath)
+// writeSettingsFile writes the library as indented JSON to path
+func writeSettingsFile(path string) error {
+	library, err := database.LoadLibrary(database.DB)
 	if err != nil {
-		return "", fmt.Errorf("failed to create settings file: %w", err)
+		return fmt.Errorf("failed to get library: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create settings file: %w", err)
 	}
 	defer file.Close()
 
@@ -142,80 +412,93 @@ func (bm *BackupManager) ExportSettings() (string, error) {
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(library); err != nil {
-		return "", fmt.Errorf("failed to encode settings: %w", err)
+		return fmt.Errorf("failed to encode settings: %w", err)
 	}
 
-	AppLogger.Info("Settings exported successfully", map[string]interface{}{
-		"file": fileName,
-		"path": filePath,
-	})
-
-	return filePath, nil
+	return nil
 }
 
-// ImportSettings imports library/settings from JSON
-func (bm *BackupManager) ImportSettings(filePath string) error {
+// ImportSettings imports library settings from a settings export, decrypting
+// the file first if it is encrypted. Replace mode makes the library match the
+// file, merge mode adds the file's entries. With dryRun nothing is saved and
+// the report shows what would change. A non-nil record is called with the
+// library before and after the import in the transaction that saves it.
+func (bm *BackupManager) ImportSettings(filePath string, mode string, dryRun bool, record func(tx *gorm.DB, before, after database.Library) error) (*SettingsImportReport, error) {
 	// Verify file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("settings file does not exist: %s", filePath)
+		return nil, fmt.Errorf("settings file does not exist: %s", filePath)
 	}
 
-	// Open file
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open settings file: %w", err)
+		return nil, fmt.Errorf("failed to open settings file: %w", err)
 	}
-	defer file.Close()
 
-	// Decode JSON
-	var library database.Library
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&library); err != nil {
-		return fmt.Errorf("failed to decode settings: %w", err)
+	if IsEncrypted(data) {
+		if data, _, err = bm.keyring.Decrypt(data); err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt settings: %v", ErrInvalidBackup, err)
+		}
 	}
 
-	// Update library in database
-	library.ID = 1 // Ensure we update the main library record
-	if err := database.DB.Save(&library).Error; err != nil {
-		return fmt.Errorf("failed to save settings: %w", err)
+	// Decode JSON
+	var imported database.Library
+	if err := json.Unmarshal(data, &imported); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode settings: %v", ErrInvalidBackup, err)
 	}
 
-	AppLogger.Info("Settings imported successfully", map[string]interface{}{
-		"from": filePath,
-	})
-
-	return nil
-}
+	current, err := database.LoadLibrary(database.DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load library: %w", err)
+	}
 
-// ListBackups lists all backup files
-func (bm *BackupManager) ListBackups() ([]string, error) {
-	entries, err := os.ReadDir(bm.backupDir)
+	library, report, err := planImport(current, imported, mode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+		return nil, err
 	}
+	report.DryRun = dryRun
 
-	var backups []string
-	for _, entry := range entries {
-		if !entry.IsDir() && (filepath.Ext(entry.Name()) == ".db" || filepath.Ext(entry.Name()) == ".json") {
-			backups = append(backups, entry.Name())
+	if dryRun || !report.Changed {
+		return report, nil
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := database.SaveLibrary(tx, library); err != nil {
+			return err
+		}
+		if record == nil {
+			return nil
 		}
+		after, err := database.LoadLibrary(tx)
+		if err != nil {
+			return err
+		}
+		return record(tx, current, after)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save settings: %w", err)
 	}
 
-	return backups, nil
+	AppLogger.Info("Settings imported successfully", map[string]interface{}{
+		"from":     filePath,
+		"mode":     mode,
+		"warnings": len(report.Warnings),
+	})
+
+	return report, nil
 }
 
-// CleanOldBackups removes backups older than specified days
-func (bm *BackupManager) CleanOldBackups(daysToKeep int) error {
+// ListBackups lists all backup files, newest first, with the parsed
+// manifest of each archive
+func (bm *BackupManager) ListBackups() ([]BackupInfo, error) {
 	entries, err := os.ReadDir(bm.backupDir)
 	if err != nil {
-		return fmt.Errorf("failed to read backup directory: %w", err)
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
 	}
 
-	cutoffTime := time.Now().AddDate(0, 0, -daysToKeep)
-	removed := 0
-
+	backups := []BackupInfo{}
 	for _, entry := range entries {
-		if entry.IsDir() {
+		kind := backupKind(entry.Name())
+		if entry.IsDir() || kind == "" || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
@@ -224,45 +507,66 @@ func (bm *BackupManager) CleanOldBackups(daysToKeep int) error {
 			continue
 		}
 
-		if info.ModTime().Before(cutoffTime) {
-			filePath := filepath.Join(bm.backupDir, entry.Name())
-			if err := os.Remove(filePath); err != nil {
-				AppLogger.Error("Failed to remove old backup", err, map[string]interface{}{
-					"file": entry.Name(),
-				})
-			} else {
-				removed++
+		backup := BackupInfo{
+			Name:       entry.Name(),
+			Kind:       kind,
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		}
+		path := filepath.Join(bm.backupDir, entry.Name())
+		backup.Pinned = isPinned(path)
+		if kind == BackupKindArchive {
+			manifest, err := readManifest(path)
+			if err != nil {
+				backup.Error = err.Error()
 			}
+			backup.Manifest = manifest
+			if manifest != nil && manifest.Encryption != nil {
+				backup.Encrypted = true
+				backup.KeyID = manifest.Encryption.KeyID
+			}
+		} else if keyID, ok := encryptedFileKeyID(path); ok {
+			backup.Encrypted = true
+			backup.KeyID = keyID
 		}
+
+		backups = append(backups, backup)
 	}
 
-	AppLogger.Info("Cleaned old backups", map[string]interface{}{
-		"removed": removed,
-		"older_than_days": daysToKeep,
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModifiedAt.After(backups[j].ModifiedAt)
 	})
 
-	return nil
+	return backups, nil
 }
 
-// StartAutoBackup starts automatic daily backups
-func (bm *BackupManager) StartAutoBackup(dbPath string, intervalHours int) {
-	ticker := time.NewTicker(time.Duration(intervalHours) * time.Hour)
-
-	go func() {
-		for range ticker.C {
-			_, err := bm.BackupDatabase(dbPath)
-			if err != nil {
-				AppLogger.Error("Auto-backup failed", err, nil)
-			}
+// RegisterJobs adds the "backup" and "prune" jobs to a scheduler. An empty
+// schedule leaves that job stopped until it is scheduled through the API.
+func (bm *BackupManager) RegisterJobs(s *Scheduler, backupSchedule string, pruneSchedule string) error {
+	err := s.Add("backup", backupSchedule, func() error {
+		result, err := bm.BackupDatabase()
+		if err != nil {
+			return err
+		}
 
-			// Clean backups older than 30 days
-			if err := bm.CleanOldBackups(30); err != nil {
-				AppLogger.Error("Failed to clean old backups", err, nil)
+		var failed []string
+		for _, upload := range result.Uploads {
+			if !upload.OK {
+				failed = append(failed, upload.Target+": "+upload.Error)
 			}
 		}
-	}()
+		if len(failed) > 0 {
+			return fmt.Errorf("backup written but upload failed: %s", strings.Join(failed, "; "))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	AppLogger.Info("Auto-backup started", map[string]interface{}{
-		"interval_hours": intervalHours,
+	return s.Add("prune", pruneSchedule, func() error {
+		_, err := bm.Prune(false)
+		return err
 	})
 }
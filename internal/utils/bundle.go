@@ -0,0 +1,430 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"chklst-go/internal/config"
+	"chklst-go/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// Bundle encodings
+const (
+	BundleJSON   = "json"
+	BundleNDJSON = "ndjson"
+)
+
+// bundleFormat and bundleVersion identify a bundle and its layout
+const (
+	bundleFormat  = "chklst-bundle"
+	bundleVersion = 1
+)
+
+// Bundle conflict handling
+const (
+	// BundleSkip leaves existing rows untouched
+	BundleSkip = "skip"
+	// BundleUpdate overwrites existing rows with the bundle's values
+	BundleUpdate = "update"
+)
+
+// ErrInvalidBundle is returned when a bundle cannot be decoded
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// errDryRun rolls back the import transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// Bundle is a portable copy of projects and their components and deployments,
+// plus the library and settings. Rows refer to each other by external ID so
+// a bundle can be imported into another instance.
+type Bundle struct {
+	BundleHeader
+	Library     *BundleLibrary     `json:"library,omitempty"`
+	Settings    *BundleSettings    `json:"settings,omitempty"`
+	Projects    []BundleProject    `json:"projects"`
+	Components  []BundleComponent  `json:"components"`
+	Deployments []BundleDeployment `json:"deployments"`
+}
+
+// BundleHeader identifies the bundle format and where it came from
+type BundleHeader struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	AppVersion    string    `json:"app_version"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+// BundleLibrary holds the library presets
+type BundleLibrary struct {
+	Developers    []string `json:"developers"`
+	BuildServers  []string `json:"build_servers"`
+	DeployServers []string `json:"deploy_servers"`
+	Environments  []string `json:"environments"`
+}
+
+// BundleSettings holds the application settings
+type BundleSettings struct {
+	DefaultDeployedBy  string `json:"default_deployed_by"`
+	ExcelExportPath    string `json:"excel_export_path"`
+	AutoClearAfterSave bool   `json:"auto_clear_after_save"`
+}
+
+// BundleProject is a project without its local primary key
+type BundleProject struct {
+	ExternalID     string    `json:"external_id"`
+	Name           string    `json:"name"`
+	BuildServer    string    `json:"build_server"`
+	DeployServer   string    `json:"deploy_server"`
+	DatabaseName   string    `json:"database_name"`
+	Environment    string    `json:"environment"`
+	BackupLocation string    `json:"backup_location"`
+	Description    string    `json:"description"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// BundleComponent is a component whose project is given by external ID
+type BundleComponent struct {
+	ExternalID   string    `json:"external_id"`
+	Project      string    `json:"project"`
+	Name         string    `json:"name"`
+	Developer    string    `json:"developer"`
+	VCSType      string    `json:"vcs_type"`
+	VCSURL       string    `json:"vcs_url"`
+	BuildCommand string    `json:"build_command"`
+	ComponentURL string    `json:"component_url"`
+	Enabled      bool      `json:"enabled"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// BundleDeployment is a deployment whose project and component are given by
+// external ID, with its checklist
+type BundleDeployment struct {
+	ExternalID          string                `json:"external_id"`
+	Project             string                `json:"project"`
+	Component           string                `json:"component,omitempty"`
+	JiraID              string                `json:"jira_id"`
+	Timestamp           time.Time             `json:"timestamp"`
+	Environment         string                `json:"environment"`
+	VCSURL              string                `json:"vcs_url"`
+	DeveloperName       string                `json:"developer_name"`
+	BuildServer         string                `json:"build_server"`
+	DeployServer        string                `json:"deploy_server"`
+	DatabaseName        string                `json:"database_name"`
+	DBBackupLocation    string                `json:"db_backup_location"`
+	DatabaseScript      string                `json:"database_script"`
+	PreviousBuildBackup string                `json:"previous_build_backup"`
+	Status              string                `json:"status"`
+	BuildStatus         string                `json:"build_status"`
+	DeployStatus        string                `json:"deploy_status"`
+	Notes               string                `json:"notes"`
+	DeployedBy          string                `json:"deployed_by"`
+	CreatedAt           time.Time             `json:"created_at"`
+	UpdatedAt           time.Time             `json:"updated_at"`
+	Checklist           []BundleChecklistItem `json:"checklist,omitempty"`
+}
+
+// BundleChecklistItem is one checklist step of a deployment
+type BundleChecklistItem struct {
+	Position    int        `json:"position"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Required    bool       `json:"required"`
+	Checked     bool       `json:"checked"`
+	CheckedBy   string     `json:"checked_by"`
+	CheckedAt   *time.Time `json:"checked_at"`
+}
+
+// bundleRecord is one line of an NDJSON bundle
+type bundleRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ExportBundle writes the given projects, or all projects when projectIDs is
+// empty, with their components, deployments and checklists, the library and
+// the settings, as JSON or NDJSON
+func ExportBundle(w io.Writer, format string, projectIDs []uint) error {
+	if format != BundleJSON && format != BundleNDJSON {
+		return fmt.Errorf("bundle format must be %q or %q", BundleJSON, BundleNDJSON)
+	}
+
+	bundle, err := loadBundle(projectIDs)
+	if err != nil {
+		return err
+	}
+
+	if format == BundleJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(bundle)
+	}
+
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	write := func(kind string, data interface{}) error {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(bundleRecord{Type: kind, Data: raw})
+	}
+
+	if err := write("header", bundle.BundleHeader); err != nil {
+		return err
+	}
+	if bundle.Library != nil {
+		if err := write("library", bundle.Library); err != nil {
+			return err
+		}
+	}
+	if bundle.Settings != nil {
+		if err := write("settings", bundle.Settings); err != nil {
+			return err
+		}
+	}
+	for _, p := range bundle.Projects {
+		if err := write("project", p); err != nil {
+			return err
+		}
+	}
+	for _, c := range bundle.Components {
+		if err := write("component", c); err != nil {
+			return err
+		}
+	}
+	for _, d := range bundle.Deployments {
+		if err := write("deployment", d); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+// loadBundle reads the bundle contents from the database
+func loadBundle(projectIDs []uint) (*Bundle, error) {
+	schemaVersion, err := database.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{
+		BundleHeader: BundleHeader{
+			Format:        bundleFormat,
+			Version:       bundleVersion,
+			ExportedAt:    time.Now().UTC(),
+			AppVersion:    config.Version,
+			SchemaVersion: schemaVersion,
+		},
+		Projects:    []BundleProject{},
+		Components:  []BundleComponent{},
+		Deployments: []BundleDeployment{},
+	}
+
+	if library, err := database.LoadLibrary(database.DB); err == nil {
+		bundle.Library = &BundleLibrary{
+			Developers:    library.Developers,
+			BuildServers:  library.BuildServers,
+			DeployServers: library.DeployServers,
+			Environments:  library.Environments,
+		}
+	}
+
+	var settings database.Settings
+	if err := database.DB.First(&settings).Error; err == nil {
+		bundle.Settings = &BundleSettings{
+			DefaultDeployedBy:  settings.DefaultDeployedBy,
+			ExcelExportPath:    settings.ExcelExportPath,
+			AutoClearAfterSave: settings.AutoClearAfterSave,
+		}
+	}
+
+	var projects []database.Project
+	query := database.DB.Order("id")
+	if len(projectIDs) > 0 {
+		query = query.Where("id IN ?", projectIDs)
+	}
+	if err := query.Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("failed to load projects: %w", err)
+	}
+
+	projectRefs := make(map[uint]string)
+	ids := []uint{}
+	for _, p := range projects {
+		projectRefs[p.ID] = p.ExternalID
+		ids = append(ids, p.ID)
+		bundle.Projects = append(bundle.Projects, BundleProject{
+			ExternalID:     p.ExternalID,
+			Name:           p.Name,
+			BuildServer:    p.BuildServer,
+			DeployServer:   p.DeployServer,
+			DatabaseName:   p.DatabaseName,
+			Environment:    p.Environment,
+			BackupLocation: p.BackupLocation,
+			Description:    p.Description,
+			CreatedAt:      p.CreatedAt,
+			UpdatedAt:      p.UpdatedAt,
+		})
+	}
+	if len(ids) == 0 {
+		return bundle, nil
+	}
+
+	var components []database.Component
+	if err := database.DB.Where("project_id IN ?", ids).Order("id").Find(&components).Error; err != nil {
+		return nil, fmt.Errorf("failed to load components: %w", err)
+	}
+
+	componentRefs := make(map[uint]string)
+	for _, c := range components {
+		componentRefs[c.ID] = c.ExternalID
+		bundle.Components = append(bundle.Components, BundleComponent{
+			ExternalID:   c.ExternalID,
+			Project:      projectRefs[c.ProjectID],
+			Name:         c.Name,
+			Developer:    c.Developer,
+			VCSType:      c.VCSType,
+			VCSURL:       c.VCSURL,
+			BuildCommand: c.BuildCommand,
+			ComponentURL: c.ComponentURL,
+			Enabled:      c.Enabled,
+			Description:  c.Description,
+			CreatedAt:    c.CreatedAt,
+			UpdatedAt:    c.UpdatedAt,
+		})
+	}
+
+	var deployments []database.Deployment
+	err = database.DB.Where("project_id IN ?", ids).
+		Preload("Checklist", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Order("id").Find(&deployments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deployments: %w", err)
+	}
+
+	for _, d := range deployments {
+		bd := BundleDeployment{
+			ExternalID:          d.ExternalID,
+			Project:             projectRefs[d.ProjectID],
+			JiraID:              d.JiraID,
+			Timestamp:           d.Timestamp,
+			Environment:         d.Environment,
+			VCSURL:              d.VCSURL,
+			DeveloperName:       d.DeveloperName,
+			BuildServer:         d.BuildServer,
+			DeployServer:        d.DeployServer,
+			DatabaseName:        d.DatabaseName,
+			DBBackupLocation:    d.DBBackupLocation,
+			DatabaseScript:      d.DatabaseScript,
+			PreviousBuildBackup: d.PreviousBuildBackup,
+			Status:              string(d.Status),
+			BuildStatus:         d.BuildStatus,
+			DeployStatus:        d.DeployStatus,
+			Notes:               d.Notes,
+			DeployedBy:          d.DeployedBy,
+			CreatedAt:           d.CreatedAt,
+			UpdatedAt:           d.UpdatedAt,
+		}
+		if d.ComponentID != nil {
+			bd.Component = componentRefs[*d.ComponentID]
+		}
+		for _, item := range d.Checklist {
+			bd.Checklist = append(bd.Checklist, BundleChecklistItem{
+				Position:    item.Position,
+				Title:       item.Title,
+				Description: item.Description,
+				Required:    item.Required,
+				Checked:     item.Checked,
+				CheckedBy:   item.CheckedBy,
+				CheckedAt:   item.CheckedAt,
+			})
+		}
+		bundle.Deployments = append(bundle.Deployments, bd)
+	}
+
+	return bundle, nil
+}
+
+// DecodeBundle reads a JSON or NDJSON bundle. The encoding is detected from
+// the first record.
+func DecodeBundle(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+	firstLine, err := br.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	var first bundleRecord
+	isNDJSON := json.Unmarshal(firstLine, &first) == nil && first.Type == "header"
+	input := io.MultiReader(bytes.NewReader(firstLine), br)
+
+	bundle := &Bundle{}
+	if isNDJSON {
+		err = decodeNDJSON(input, bundle)
+	} else {
+		err = json.NewDecoder(input).Decode(bundle)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	if bundle.Format != bundleFormat {
+		return nil, fmt.Errorf("%w: not a chklst bundle", ErrInvalidBundle)
+	}
+	if bundle.Version > bundleVersion {
+		return nil, fmt.Errorf("%w: bundle version %d is newer than supported version %d", ErrInvalidBundle, bundle.Version, bundleVersion)
+	}
+
+	return bundle, nil
+}
+
+// decodeNDJSON collects the records of an NDJSON bundle
+func decodeNDJSON(r io.Reader, bundle *Bundle) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var record bundleRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		var err error
+		switch record.Type {
+		case "header":
+			err = json.Unmarshal(record.Data, &bundle.BundleHeader)
+		case "library":
+			err = json.Unmarshal(record.Data, &bundle.Library)
+		case "settings":
+			err = json.Unmarshal(record.Data, &bundle.Settings)
+		case "project":
+			var p BundleProject
+			err = json.Unmarshal(record.Data, &p)
+			bundle.Projects = append(bundle.Projects, p)
+		case "component":
+			var c BundleComponent
+			err = json.Unmarshal(record.Data, &c)
+			bundle.Components = append(bundle.Components, c)
+		case "deployment":
+			var d BundleDeployment
+			err = json.Unmarshal(record.Data, &d)
+			bundle.Deployments = append(bundle.Deployments, d)
+		default:
+			err = fmt.Errorf("unknown record type %q", record.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"chklst-go/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// BundleImportOptions controls how a bundle is applied
+type BundleImportOptions struct {
+	// OnConflict is BundleSkip or BundleUpdate and decides what happens to
+	// rows that already exist locally
+	OnConflict string
+	// DryRun runs the import in a transaction that is rolled back
+	DryRun bool
+	// Record, when set, is called with the report in the import's
+	// transaction before it commits. It is not called on a dry run.
+	Record func(tx *gorm.DB, report *BundleImportReport) error
+}
+
+// BundleItem identifies one row of a bundle in an import report
+type BundleItem struct {
+	ExternalID string `json:"external_id"`
+	ID         uint   `json:"id,omitempty"`
+	Name       string `json:"name"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// BundleEntityReport lists what happened to the rows of one entity
+type BundleEntityReport struct {
+	Created []BundleItem `json:"created"`
+	Updated []BundleItem `json:"updated"`
+	Skipped []BundleItem `json:"skipped"`
+}
+
+// BundleImportReport describes what a bundle import created, updated and
+// skipped, or with DryRun what it would do
+type BundleImportReport struct {
+	DryRun      bool                  `json:"dry_run"`
+	OnConflict  string                `json:"on_conflict"`
+	Projects    *BundleEntityReport   `json:"projects"`
+	Components  *BundleEntityReport   `json:"components"`
+	Deployments *BundleEntityReport   `json:"deployments"`
+	Library     *SettingsImportReport `json:"library,omitempty"`
+	Settings    string                `json:"settings,omitempty"` // "updated" or "skipped"
+}
+
+// bundleImport holds the state of one import: the report and the mapping
+// from the bundle's external IDs to local primary keys
+type bundleImport struct {
+	tx         *gorm.DB
+	update     bool
+	report     *BundleImportReport
+	projects   map[string]uint
+	components map[string]uint
+}
+
+// newEntityReport returns an empty entity report
+func newEntityReport() *BundleEntityReport {
+	return &BundleEntityReport{Created: []BundleItem{}, Updated: []BundleItem{}, Skipped: []BundleItem{}}
+}
+
+// ImportBundle applies a bundle to the database in one transaction. Projects
+// are matched by external ID, then by name; components by external ID, then
+// by name within their project; deployments by external ID, then by Jira ID.
+// Matched rows are skipped or updated according to opts.OnConflict, the
+// library is merged and references are remapped to local primary keys.
+func ImportBundle(bundle *Bundle, opts BundleImportOptions) (*BundleImportReport, error) {
+	if opts.OnConflict != BundleSkip && opts.OnConflict != BundleUpdate {
+		return nil, fmt.Errorf("on_conflict must be %q or %q", BundleSkip, BundleUpdate)
+	}
+
+	imp := &bundleImport{
+		update: opts.OnConflict == BundleUpdate,
+		report: &BundleImportReport{
+			DryRun:      opts.DryRun,
+			OnConflict:  opts.OnConflict,
+			Projects:    newEntityReport(),
+			Components:  newEntityReport(),
+			Deployments: newEntityReport(),
+		},
+		projects:   make(map[string]uint),
+		components: make(map[string]uint),
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		imp.tx = tx
+
+		if err := imp.importLibrary(bundle.Library); err != nil {
+			return err
+		}
+		if err := imp.importSettings(bundle.Settings); err != nil {
+			return err
+		}
+		for _, p := range bundle.Projects {
+			if err := imp.importProject(p); err != nil {
+				return err
+			}
+		}
+		for _, c := range bundle.Components {
+			if err := imp.importComponent(c); err != nil {
+				return err
+			}
+		}
+		for _, d := range bundle.Deployments {
+			if err := imp.importDeployment(d); err != nil {
+				return err
+			}
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		if opts.Record != nil {
+			return opts.Record(tx, imp.report)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		// Rows created in the rolled back transaction have no ID
+		for _, report := range []*BundleEntityReport{imp.report.Projects, imp.report.Components, imp.report.Deployments} {
+			for i := range report.Created {
+				report.Created[i].ID = 0
+			}
+		}
+	}
+
+	if !opts.DryRun {
+		AppLogger.Info("Bundle imported", map[string]interface{}{
+			"projects_created":    len(imp.report.Projects.Created),
+			"components_created":  len(imp.report.Components.Created),
+			"deployments_created": len(imp.report.Deployments.Created),
+			"on_conflict":         opts.OnConflict,
+		})
+	}
+
+	return imp.report, nil
+}
+
+// importLibrary merges the bundle's library into the local one
+func (imp *bundleImport) importLibrary(bl *BundleLibrary) error {
+	if bl == nil {
+		return nil
+	}
+
+	current, err := database.LoadLibrary(imp.tx)
+	if err != nil {
+		return fmt.Errorf("failed to load library: %w", err)
+	}
+
+	imported := database.Library{
+		Developers:    bl.Developers,
+		BuildServers:  bl.BuildServers,
+		DeployServers: bl.DeployServers,
+		Environments:  bl.Environments,
+	}
+	library, report, err := planImport(current, imported, ImportMerge)
+	if err != nil {
+		return err
+	}
+	imp.report.Library = report
+
+	if !report.Changed {
+		return nil
+	}
+	return database.SaveLibrary(imp.tx, library)
+}
+
+// importSettings overwrites the local settings when conflicts are updated
+func (imp *bundleImport) importSettings(bs *BundleSettings) error {
+	if bs == nil {
+		return nil
+	}
+	if !imp.update {
+		imp.report.Settings = "skipped"
+		return nil
+	}
+
+	var settings database.Settings
+	if err := imp.tx.FirstOrInit(&settings, database.Settings{ID: 1}).Error; err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	settings.DefaultDeployedBy = bs.DefaultDeployedBy
+	settings.ExcelExportPath = bs.ExcelExportPath
+	settings.AutoClearAfterSave = bs.AutoClearAfterSave
+	settings.Version++
+	if err := imp.tx.Save(&settings).Error; err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	imp.report.Settings = "updated"
+	return nil
+}
+
+// importProject creates, updates or skips one project
+func (imp *bundleImport) importProject(bp BundleProject) error {
+	item := BundleItem{ExternalID: bp.ExternalID, Name: bp.Name}
+	report := imp.report.Projects
+
+	if bp.ExternalID == "" || bp.Name == "" {
+		item.Reason = "external_id and name are required"
+		report.Skipped = append(report.Skipped, item)
+		return nil
+	}
+
+	var existing database.Project
+	found, reason, err := imp.match(&existing, bp.ExternalID, "duplicate name", "name = ?", bp.Name)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		item.ID = existing.ID
+		imp.projects[bp.ExternalID] = existing.ID
+		if !imp.update {
+			item.Reason = reason
+			report.Skipped = append(report.Skipped, item)
+			return nil
+		}
+
+		// A rename must not collide with another local project
+		var clash int64
+		imp.tx.Model(&database.Project{}).Where("name = ? AND id <> ?", bp.Name, existing.ID).Count(&clash)
+		if clash > 0 {
+			item.Reason = "name already used by another project"
+			report.Skipped = append(report.Skipped, item)
+			return nil
+		}
+
+		existing.Name = bp.Name
+		existing.BuildServer = bp.BuildServer
+		existing.DeployServer = bp.DeployServer
+		existing.DatabaseName = bp.DatabaseName
+		existing.Environment = bp.Environment
+		existing.BackupLocation = bp.BackupLocation
+		existing.Description = bp.Description
+		existing.Version++
+		if err := imp.tx.Save(&existing).Error; err != nil {
+			return fmt.Errorf("failed to update project %s: %w", bp.Name, err)
+		}
+		report.Updated = append(report.Updated, item)
+		return nil
+	}
+
+	project := database.Project{
+		ExternalID:     bp.ExternalID,
+		Name:           bp.Name,
+		BuildServer:    bp.BuildServer,
+		DeployServer:   bp.DeployServer,
+		DatabaseName:   bp.DatabaseName,
+		Environment:    bp.Environment,
+		BackupLocation: bp.BackupLocation,
+		Description:    bp.Description,
+		CreatedAt:      bp.CreatedAt,
+	}
+	if err := imp.tx.Create(&project).Error; err != nil {
+		return fmt.Errorf("failed to create project %s: %w", bp.Name, err)
+	}
+
+	item.ID = project.ID
+	imp.projects[bp.ExternalID] = project.ID
+	report.Created = append(report.Created, item)
+	return nil
+}
+
+// importComponent creates, updates or skips one component
+func (imp *bundleImport) importComponent(bc BundleComponent) error {
+	item := BundleItem{ExternalID: bc.ExternalID, Name: bc.Name}
+	report := imp.report.Components
+
+	if bc.ExternalID == "" || bc.Name == "" {
+		item.Reason = "external_id and name are required"
+		report.Skipped = append(report.Skipped, item)
+		return nil
+	}
+
+	projectID, ok := imp.projects[bc.Project]
+	if !ok {
+		item.Reason = "project not imported"
+		report.Skipped = append(report.Skipped, item)
+		return nil
+	}
+
+	var existing database.Component
+	found, reason, err := imp.match(&existing, bc.ExternalID, "duplicate name", "project_id = ? AND name = ?", projectID, bc.Name)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		item.ID = existing.ID
+		imp.components[bc.ExternalID] = existing.ID
+		if !imp.update || existing.ProjectID != projectID {
+			item.Reason = reason
+			if existing.ProjectID != projectID {
+				item.Reason = "belongs to another project"
+			}
+			report.Skipped = append(report.Skipped, item)
+			return nil
+		}
+
+		existing.Name = bc.Name
+		existing.Developer = bc.Developer
+		existing.VCSType = bc.VCSType
+		existing.VCSURL = bc.VCSURL
+		existing.BuildCommand = bc.BuildCommand
+		existing.ComponentURL = bc.ComponentURL
+		existing.Enabled = bc.Enabled
+		existing.Description = bc.Description
+		existing.Version++
+		if err := imp.tx.Save(&existing).Error; err != nil {
+			return fmt.Errorf("failed to update component %s: %w", bc.Name, err)
+		}
+		report.Updated = append(report.Updated, item)
+		return nil
+	}
+
+	component := database.Component{
+		ExternalID:   bc.ExternalID,
+		ProjectID:    projectID,
+		Name:         bc.Name,
+		Developer:    bc.Developer,
+		VCSType:      bc.VCSType,
+		VCSURL:       bc.VCSURL,
+		BuildCommand: bc.BuildCommand,
+		ComponentURL: bc.ComponentURL,
+		Enabled:      bc.Enabled,
+		Description:  bc.Description,
+		CreatedAt:    bc.CreatedAt,
+	}
+	if err := imp.tx.Create(&component).Error; err != nil {
+		return fmt.Errorf("failed to create component %s: %w", bc.Name, err)
+	}
+	// Create skips zero values that have a column default, so a disabled
+	// component has to be written explicitly
+	if !bc.Enabled {
+		if err := imp.tx.Model(&component).Update("enabled", false).Error; err != nil {
+			return fmt.Errorf("failed to create component %s: %w", bc.Name, err)
+		}
+	}
+
+	item.ID = component.ID
+	imp.components[bc.ExternalID] = component.ID
+	report.Created = append(report.Created, item)
+	return nil
+}
+
+// importDeployment creates, updates or skips one deployment and its checklist
+func (imp *bundleImport) importDeployment(bd BundleDeployment) error {
+	item := BundleItem{ExternalID: bd.ExternalID, Name: bd.JiraID}
+	report := imp.report.Deployments
+
+	projectID, ok := imp.projects[bd.Project]
+	if !ok {
+		item.Reason = "project not imported"
+		report.Skipped = append(report.Skipped, item)
+		return nil
+	}
+
+	var componentID *uint
+	if bd.Component != "" {
+		id, ok := imp.components[bd.Component]
+		if !ok {
+			item.Reason = "component not imported"
+			report.Skipped = append(report.Skipped, item)
+			return nil
+		}
+		componentID = &id
+	}
+
+	var existing database.Deployment
+	var found bool
+	var reason string
+	var err error
+	if bd.JiraID != "" {
+		found, reason, err = imp.match(&existing, bd.ExternalID, "duplicate Jira ID", "jira_id = ?", bd.JiraID)
+	} else {
+		found, reason, err = imp.match(&existing, bd.ExternalID, "", "")
+	}
+	if err != nil {
+		return err
+	}
+
+	deployment := existing
+	deployment.ProjectID = projectID
+	deployment.ComponentID = componentID
+	deployment.JiraID = bd.JiraID
+	deployment.Timestamp = bd.Timestamp
+	deployment.Environment = bd.Environment
+	deployment.VCSURL = bd.VCSURL
+	deployment.DeveloperName = bd.DeveloperName
+	deployment.BuildServer = bd.BuildServer
+	deployment.DeployServer = bd.DeployServer
+	deployment.DatabaseName = bd.DatabaseName
+	deployment.DBBackupLocation = bd.DBBackupLocation
+	deployment.DatabaseScript = bd.DatabaseScript
+	deployment.PreviousBuildBackup = bd.PreviousBuildBackup
+	deployment.Status = database.DeploymentStatus(bd.Status)
+	deployment.BuildStatus = bd.BuildStatus
+	deployment.DeployStatus = bd.DeployStatus
+	deployment.Notes = bd.Notes
+	deployment.DeployedBy = bd.DeployedBy
+
+	if found {
+		item.ID = existing.ID
+		if !imp.update {
+			item.Reason = reason
+			report.Skipped = append(report.Skipped, item)
+			return nil
+		}
+
+		deployment.Version++
+		if err := imp.tx.Save(&deployment).Error; err != nil {
+			return fmt.Errorf("failed to update deployment %s: %w", bd.ExternalID, err)
+		}
+		if err := imp.tx.Where("deployment_id = ?", deployment.ID).Delete(&database.ChecklistItem{}).Error; err != nil {
+			return fmt.Errorf("failed to replace checklist: %w", err)
+		}
+		if err := imp.createChecklist(deployment.ID, bd.Checklist); err != nil {
+			return err
+		}
+		report.Updated = append(report.Updated, item)
+		return nil
+	}
+
+	deployment.ExternalID = bd.ExternalID
+	deployment.CreatedAt = bd.CreatedAt
+	if err := imp.tx.Create(&deployment).Error; err != nil {
+		return fmt.Errorf("failed to create deployment %s: %w", bd.ExternalID, err)
+	}
+	if err := imp.createChecklist(deployment.ID, bd.Checklist); err != nil {
+		return err
+	}
+
+	item.ID = deployment.ID
+	report.Created = append(report.Created, item)
+	return nil
+}
+
+// createChecklist inserts the checklist items of an imported deployment
+func (imp *bundleImport) createChecklist(deploymentID uint, items []BundleChecklistItem) error {
+	for _, bi := range items {
+		item := database.ChecklistItem{
+			DeploymentID: deploymentID,
+			Position:     bi.Position,
+			Title:        bi.Title,
+			Description:  bi.Description,
+			Required:     bi.Required,
+			Checked:      bi.Checked,
+			CheckedBy:    bi.CheckedBy,
+			CheckedAt:    bi.CheckedAt,
+		}
+		if err := imp.tx.Create(&item).Error; err != nil {
+			return fmt.Errorf("failed to create checklist item: %w", err)
+		}
+	}
+	return nil
+}
+
+// match looks for an existing row by external ID and then by the natural key
+// condition, if given. It reports whether a row was found and why it matched,
+// using duplicate as the reason for a natural key match.
+func (imp *bundleImport) match(dest interface{}, externalID string, duplicate string, cond string, args ...interface{}) (bool, string, error) {
+	if externalID != "" {
+		err := imp.tx.Where("external_id = ?", externalID).Take(dest).Error
+		if err == nil {
+			return true, "external ID already exists", nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, "", err
+		}
+	}
+
+	if cond == "" {
+		return false, "", nil
+	}
+
+	err := imp.tx.Where(cond, args...).Order("id").Take(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	return true, duplicate, nil
+}
@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EncryptionAlgorithm names the cipher used for encrypted backups
+const EncryptionAlgorithm = "AES-256-GCM"
+
+// encryptedExt is appended to the name of every encrypted backup file
+const encryptedExt = ".enc"
+
+// encryptedMagic prefixes every encrypted file, followed by a one-byte key
+// ID length, the key ID, the nonce and the sealed, gzip-compressed payload.
+// The whole header is authenticated as additional data.
+var encryptedMagic = []byte("CHKLSTENC1")
+
+// ErrNoBackupKey is returned when an encrypted backup is read without a
+// matching key
+var ErrNoBackupKey = errors.New("no backup key configured for this backup")
+
+// Keyring holds the backup encryption keys. The first key encrypts new
+// backups; every key can decrypt, so old keys stay listed after rotation
+// until the backups they protect have expired.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// LoadKeyring builds a keyring from a comma-separated key list and/or a key
+// file with one key per line. Each entry is `id:key` or a bare key, whose ID
+// is then derived from its fingerprint. Keys are 32 bytes, base64 or hex
+// encoded. Returns nil when no keys are configured.
+func LoadKeyring(keyList string, keyFile string) (*Keyring, error) {
+	entries := strings.Split(keyList, ",")
+
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup key file: %w", err)
+		}
+		entries = append(entries, strings.Split(string(data), "\n")...)
+	}
+
+	keyring := &Keyring{keys: make(map[string][]byte)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded := "", entry
+		if i := strings.Index(entry, ":"); i >= 0 {
+			id, encoded = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			id = keyFingerprint(key)
+		}
+		if len(id) > 255 {
+			return nil, fmt.Errorf("backup key ID too long: %s", id)
+		}
+		if _, exists := keyring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate backup key ID: %s", id)
+		}
+
+		keyring.keys[id] = key
+		if keyring.activeID == "" {
+			keyring.activeID = id
+		}
+	}
+
+	if keyring.activeID == "" {
+		return nil, nil
+	}
+
+	return keyring, nil
+}
+
+// ActiveKeyID returns the ID of the key used to encrypt new backups
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// Encrypt compresses plaintext and seals it with the active key
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(k.keys[k.activeID])
+	if err != nil {
+		return nil, err
+	}
+
+	// Ciphertext does not compress, so compress first
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(encryptedMagic)+1+len(k.activeID)+gcm.NonceSize())
+	header = append(header, encryptedMagic...)
+	header = append(header, byte(len(k.activeID)))
+	header = append(header, k.activeID...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, compressed.Bytes(), header), nil
+}
+
+// Decrypt opens data sealed by Encrypt with whichever key it names. A nil
+// keyring can only report ErrNoBackupKey.
+func (k *Keyring) Decrypt(data []byte) ([]byte, string, error) {
+	keyID, err := EncryptedKeyID(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var key []byte
+	if k != nil {
+		key = k.keys[keyID]
+	}
+	if key == nil {
+		return nil, keyID, fmt.Errorf("%w (key ID %s)", ErrNoBackupKey, keyID)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, keyID, err
+	}
+
+	headerLen := len(encryptedMagic) + 1 + len(keyID)
+	if len(data) < headerLen+gcm.NonceSize() {
+		return nil, keyID, errors.New("encrypted data is truncated")
+	}
+	header := data[:headerLen+gcm.NonceSize()]
+	nonce := data[headerLen : headerLen+gcm.NonceSize()]
+
+	compressed, err := gcm.Open(nil, nonce, data[len(header):], header)
+	if err != nil {
+		return nil, keyID, errors.New("failed to decrypt: data is corrupt or was encrypted with a different key")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, keyID, fmt.Errorf("failed to decompress: %w", err)
+	}
+	defer gz.Close()
+
+	plaintext, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, keyID, fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	return plaintext, keyID, nil
+}
+
+// EncryptFile encrypts src into dst with the active key
+func (k *Keyring) EncryptFile(src string, dst string) error {
+	plaintext, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := k.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+
+	return writeFileSync(dst, ciphertext)
+}
+
+// DecryptFile decrypts src into dst
+func (k *Keyring) DecryptFile(src string, dst string) error {
+	ciphertext, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	plaintext, _, err := k.Decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+
+	return writeFileSync(dst, plaintext)
+}
+
+// IsEncrypted reports whether data starts with the encrypted file header
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// IsEncryptedFile reports whether the file at path is encrypted
+func IsEncryptedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	head := make([]byte, len(encryptedMagic))
+	n, _ := file.Read(head)
+
+	return IsEncrypted(head[:n]), nil
+}
+
+// encryptedFileKeyID reads the key ID from the header of an encrypted file
+func encryptedFileKeyID(path string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	head := make([]byte, len(encryptedMagic)+1+255)
+	n, _ := io.ReadFull(file, head)
+
+	keyID, err := EncryptedKeyID(head[:n])
+	return keyID, err == nil
+}
+
+// EncryptedKeyID returns the key ID recorded in an encrypted file header
+func EncryptedKeyID(data []byte) (string, error) {
+	if !IsEncrypted(data) || len(data) < len(encryptedMagic)+1 {
+		return "", errors.New("data is not encrypted")
+	}
+
+	idLen := int(data[len(encryptedMagic)])
+	start := len(encryptedMagic) + 1
+	if len(data) < start+idLen {
+		return "", errors.New("encrypted data is truncated")
+	}
+
+	return string(data[start : start+idLen]), nil
+}
+
+// decodeKey parses a 32-byte key given as base64 or hex
+func decodeKey(encoded string) ([]byte, error) {
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+
+	return nil, errors.New("backup key must be 32 bytes, base64 or hex encoded")
+}
+
+// keyFingerprint derives a short, stable ID for a key
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// newGCM creates an AES-GCM cipher for a 32-byte key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// writeFileSync writes data to path and syncs it to disk
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
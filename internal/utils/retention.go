@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupPrefix marks the files that the retention policy manages. Pre-restore
+// snapshots and settings exports are never pruned.
+const backupPrefix = "chklst_backup_"
+
+// pinSuffix names the marker file that pins a backup
+const pinSuffix = ".pin"
+
+// RetentionPolicy is a grandfather-father-son policy: the newest backup of
+// each of the last Hourly hours, Daily days, Weekly ISO weeks and Monthly
+// months is kept. A policy of all zeroes keeps everything.
+type RetentionPolicy struct {
+	Hourly  int `json:"hourly"`
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+// Disabled reports whether the policy keeps every backup
+func (p RetentionPolicy) Disabled() bool {
+	return p.Hourly <= 0 && p.Daily <= 0 && p.Weekly <= 0 && p.Monthly <= 0
+}
+
+// PruneDecision explains why a backup is kept or pruned
+type PruneDecision struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Reasons   []string  `json:"reasons,omitempty"`
+}
+
+// PruneResult lists the backups a prune run keeps and removes
+type PruneResult struct {
+	DryRun bool            `json:"dry_run"`
+	Policy RetentionPolicy `json:"policy"`
+	Kept   []PruneDecision `json:"kept"`
+	Pruned []PruneDecision `json:"pruned"`
+}
+
+// retentionBucket groups backups into periods for one tier of the policy
+type retentionBucket struct {
+	reason string
+	keep   int
+	period func(t time.Time) string
+}
+
+// Prune applies the retention policy to the backup directory and removes the
+// pruned backups from every target as well. With dryRun it only reports what
+// would be removed.
+func (bm *BackupManager) Prune(dryRun bool) (*PruneResult, error) {
+	result, err := bm.pruneLocal(dryRun)
+	if err != nil || dryRun {
+		return result, err
+	}
+
+	for _, decision := range result.Pruned {
+		bm.deleteRemote(decision.Name)
+	}
+
+	return result, nil
+}
+
+// pruneLocal applies the retention policy to the backup directory
+func (bm *BackupManager) pruneLocal(dryRun bool) (*PruneResult, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	backups, err := bm.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PruneResult{
+		DryRun: dryRun,
+		Policy: bm.retention,
+		Kept:   []PruneDecision{},
+		Pruned: []PruneDecision{},
+	}
+
+	var candidates []PruneDecision
+	for _, backup := range backups {
+		if !strings.HasPrefix(backup.Name, backupPrefix) {
+			continue
+		}
+
+		decision := PruneDecision{Name: backup.Name, CreatedAt: backupTime(backup)}
+		switch {
+		case backup.Pinned:
+			decision.Reasons = []string{"pinned"}
+		case bm.retention.Disabled():
+			decision.Reasons = []string{"retention disabled"}
+		}
+		candidates = append(candidates, decision)
+	}
+
+	// Newest first, so the first backup seen in each period is the one kept
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreatedAt.After(candidates[j].CreatedAt)
+	})
+
+	buckets := []retentionBucket{
+		{"hourly", bm.retention.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{"daily", bm.retention.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", bm.retention.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", bm.retention.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, bucket := range buckets {
+		seen := make(map[string]bool)
+		for i := range candidates {
+			if len(seen) >= bucket.keep {
+				break
+			}
+			period := bucket.period(candidates[i].CreatedAt.Local())
+			if seen[period] {
+				continue
+			}
+			seen[period] = true
+			candidates[i].Reasons = append(candidates[i].Reasons, bucket.reason)
+		}
+	}
+
+	for _, decision := range candidates {
+		if len(decision.Reasons) > 0 {
+			result.Kept = append(result.Kept, decision)
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(filepath.Join(bm.backupDir, decision.Name)); err != nil {
+				AppLogger.Error("Failed to remove old backup", err, map[string]interface{}{
+					"file": decision.Name,
+				})
+				continue
+			}
+		}
+		result.Pruned = append(result.Pruned, decision)
+	}
+
+	if !dryRun {
+		AppLogger.Info("Pruned old backups", map[string]interface{}{
+			"removed": len(result.Pruned),
+			"kept":    len(result.Kept),
+		})
+	}
+
+	return result, nil
+}
+
+// backupTime returns when a backup was taken: the manifest's creation time,
+// else the timestamp in its name, else the file's modification time
+func backupTime(backup BackupInfo) time.Time {
+	if backup.Manifest != nil && !backup.Manifest.CreatedAt.IsZero() {
+		return backup.Manifest.CreatedAt
+	}
+
+	stamp := strings.TrimPrefix(backup.Name, backupPrefix)
+	if len(stamp) >= len("2006-01-02_15-04-05") {
+		if t, err := time.ParseInLocation("2006-01-02_15-04-05", stamp[:19], time.Local); err == nil {
+			return t
+		}
+	}
+
+	return backup.ModifiedAt
+}
+
+// PinBackup protects a backup from pruning, or releases it
+func (bm *BackupManager) PinBackup(name string, pinned bool) error {
+	path, err := bm.BackupPath(name)
+	if err != nil {
+		return err
+	}
+
+	if !pinned {
+		if err := os.Remove(path + pinSuffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to unpin backup: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(path+pinSuffix, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to pin backup: %w", err)
+	}
+
+	return nil
+}
+
+// isPinned reports whether a pin marker exists for the backup at path
+func isPinned(path string) bool {
+	_, err := os.Stat(path + pinSuffix)
+	return err == nil
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// retentionBackups are named after local times, newest first. 2 March 2026
+// is a Monday, so A to C fall in ISO week 10, D and E in week 9, F in week 8.
+var retentionBackups = map[string]time.Time{
+	"A": time.Date(2026, 3, 2, 10, 30, 0, 0, time.Local),
+	"B": time.Date(2026, 3, 2, 10, 10, 0, 0, time.Local),
+	"C": time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local),
+	"D": time.Date(2026, 3, 1, 23, 0, 0, 0, time.Local),
+	"E": time.Date(2026, 2, 28, 12, 0, 0, 0, time.Local),
+	"F": time.Date(2026, 2, 20, 12, 0, 0, 0, time.Local),
+	"G": time.Date(2026, 1, 15, 12, 0, 0, 0, time.Local),
+	"H": time.Date(2025, 12, 1, 12, 0, 0, 0, time.Local),
+}
+
+// backupName returns the archive name of one of the retentionBackups
+func backupName(label string) string {
+	return backupPrefix + retentionBackups[label].Format("2006-01-02_15-04-05") + archiveExt
+}
+
+// newRetentionDir writes every one of the retentionBackups, plus a
+// pre-restore snapshot and a settings export, into a new backup directory
+func newRetentionDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for label := range retentionBackups {
+		writeBackupFile(t, dir, backupName(label), label)
+	}
+	writeBackupFile(t, dir, "pre_restore_2020-01-01_00-00-00.db", "snapshot")
+	writeBackupFile(t, dir, "settings_export_2020-01-01_00-00-00.json", "{}")
+	return dir
+}
+
+// labelsOf maps prune decisions back to backup labels and their reasons
+func labelsOf(decisions []PruneDecision) map[string][]string {
+	labels := make(map[string][]string)
+	for _, decision := range decisions {
+		for label := range retentionBackups {
+			if decision.Name == backupName(label) {
+				labels[label] = decision.Reasons
+			}
+		}
+	}
+	return labels
+}
+
+func TestPruneBuckets(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		kept   map[string][]string
+	}{
+		{"hourly keeps the newest of each hour", RetentionPolicy{Hourly: 2}, map[string][]string{
+			"A": {"hourly"},
+			"C": {"hourly"},
+		}},
+		{"daily", RetentionPolicy{Daily: 3}, map[string][]string{
+			"A": {"daily"},
+			"D": {"daily"},
+			"E": {"daily"},
+		}},
+		{"weekly uses ISO weeks", RetentionPolicy{Weekly: 2}, map[string][]string{
+			"A": {"weekly"},
+			"D": {"weekly"},
+		}},
+		{"monthly", RetentionPolicy{Monthly: 3}, map[string][]string{
+			"A": {"monthly"},
+			"E": {"monthly"},
+			"G": {"monthly"},
+		}},
+		{"tiers combine", RetentionPolicy{Hourly: 1, Daily: 2, Weekly: 3, Monthly: 4}, map[string][]string{
+			"A": {"hourly", "daily", "weekly", "monthly"},
+			"D": {"daily", "weekly"},
+			"E": {"monthly"},
+			"F": {"weekly"},
+			"G": {"monthly"},
+			"H": {"monthly"},
+		}},
+		{"more periods than backups", RetentionPolicy{Monthly: 12}, map[string][]string{
+			"A": {"monthly"},
+			"E": {"monthly"},
+			"G": {"monthly"},
+			"H": {"monthly"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bm := NewBackupManager(newRetentionDir(t), BackupOptions{Retention: tt.policy})
+
+			result, err := bm.Prune(true)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			if kept := labelsOf(result.Kept); !reflect.DeepEqual(kept, tt.kept) {
+				t.Fatalf("kept = %v, want %v", kept, tt.kept)
+			}
+			if len(result.Kept)+len(result.Pruned) != len(retentionBackups) {
+				t.Fatalf("kept %d and pruned %d, want %d backups between them", len(result.Kept), len(result.Pruned), len(retentionBackups))
+			}
+			for label := range labelsOf(result.Pruned) {
+				if _, ok := tt.kept[label]; ok {
+					t.Fatalf("%s is both kept and pruned", label)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneDisabledKeepsEverything(t *testing.T) {
+	bm := NewBackupManager(newRetentionDir(t), BackupOptions{})
+
+	result, err := bm.Prune(false)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(result.Pruned) != 0 || len(result.Kept) != len(retentionBackups) {
+		t.Fatalf("kept %d and pruned %d, want all %d kept", len(result.Kept), len(result.Pruned), len(retentionBackups))
+	}
+	for label, reasons := range labelsOf(result.Kept) {
+		if !reflect.DeepEqual(reasons, []string{"retention disabled"}) {
+			t.Errorf("%s kept for %v", label, reasons)
+		}
+	}
+}
+
+func TestPruneKeepsPinnedBackups(t *testing.T) {
+	dir := newRetentionDir(t)
+	bm := NewBackupManager(dir, BackupOptions{Retention: RetentionPolicy{Daily: 1}})
+
+	for _, label := range []string{"A", "C", "H"} {
+		if err := bm.PinBackup(backupName(label), true); err != nil {
+			t.Fatalf("PinBackup(%s): %v", label, err)
+		}
+	}
+	if err := bm.PinBackup(backupName("A"), false); err != nil {
+		t.Fatalf("unpinning A: %v", err)
+	}
+
+	result, err := bm.Prune(false)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	want := map[string][]string{
+		"A": {"daily"},
+		"C": {"pinned"},
+		"H": {"pinned"},
+	}
+	if kept := labelsOf(result.Kept); !reflect.DeepEqual(kept, want) {
+		t.Fatalf("kept = %v, want %v", kept, want)
+	}
+
+	// Only the pruned archives are removed; pre-restore snapshots and
+	// settings exports are never touched
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	wantNames := []string{
+		backupName("H"),
+		backupName("H") + pinSuffix,
+		backupName("C"),
+		backupName("C") + pinSuffix,
+		backupName("A"),
+		"pre_restore_2020-01-01_00-00-00.db",
+		"settings_export_2020-01-01_00-00-00.json",
+	}
+	if len(names) != len(wantNames) {
+		t.Fatalf("backup directory holds %v, want %v", names, wantNames)
+	}
+	for _, name := range wantNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestBackupTime(t *testing.T) {
+	modified := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+	created := time.Date(2026, 4, 5, 6, 7, 8, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		backup BackupInfo
+		want   time.Time
+	}{
+		{"manifest", BackupInfo{Name: backupName("A"), ModifiedAt: modified, Manifest: &BackupManifest{CreatedAt: created}}, created},
+		{"name", BackupInfo{Name: backupName("A"), ModifiedAt: modified}, retentionBackups["A"]},
+		{"name of an encrypted archive", BackupInfo{Name: backupName("A") + encryptedExt, ModifiedAt: modified}, retentionBackups["A"]},
+		{"unparseable name", BackupInfo{Name: backupPrefix + "latest" + archiveExt, ModifiedAt: modified}, modified},
+	}
+
+	for _, tt := range tests {
+		if got := backupTime(tt.backup); !got.Equal(tt.want) {
+			t.Errorf("%s: backupTime = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Job outcomes reported by the scheduler
+const (
+	JobOutcomeSuccess = "success"
+	JobOutcomeFailed  = "failed"
+	JobOutcomeRunning = "running"
+)
+
+// ErrJobNotFound is returned for an unknown job name
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobRunning is returned when a job is triggered while already running
+var ErrJobRunning = errors.New("job is already running")
+
+// JobStatus reports the schedule and most recent run of a job
+type JobStatus struct {
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule"`
+	Enabled    bool       `json:"enabled"`
+	Running    bool       `json:"running"`
+	LastRun    *time.Time `json:"last_run"`
+	NextRun    *time.Time `json:"next_run"`
+	DurationMs int64      `json:"duration_ms"`
+	Outcome    string     `json:"outcome,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Runs       int        `json:"runs"`
+}
+
+// scheduledJob is a named task with a cron schedule and its run history
+type scheduledJob struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	run      func() error
+	entryID  cron.EntryID
+	enabled  bool
+	running  bool
+	lastRun  time.Time
+	duration time.Duration
+	outcome  string
+	err      string
+	runs     int
+}
+
+// Scheduler runs named jobs on cron schedules. Jobs can be stopped, started,
+// rescheduled and triggered by hand while the scheduler runs, and a job
+// never overlaps with itself.
+type Scheduler struct {
+	cron   *cron.Cron
+	mu     sync.Mutex
+	jobs   []*scheduledJob
+	manual sync.WaitGroup // runs triggered by RunNow
+}
+
+// cronParser accepts standard five-field expressions and descriptors such
+// as @daily or @every 6h
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// NewScheduler creates a stopped scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		cron: cron.New(cron.WithParser(cronParser)),
+	}
+}
+
+// Add registers a job. An empty spec registers the job disabled, so it can
+// still be run by hand or scheduled later.
+func (s *Scheduler) Add(name string, spec string, run func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.name == name {
+			return fmt.Errorf("job %s already registered", name)
+		}
+	}
+
+	job := &scheduledJob{name: name, run: run}
+	s.jobs = append(s.jobs, job)
+
+	if spec == "" {
+		return nil
+	}
+
+	return s.schedule(job, spec)
+}
+
+// Start begins running scheduled jobs
+func (s *Scheduler) Start() {
+	s.cron.Start()
+
+	AppLogger.Info("Scheduler started", map[string]interface{}{
+		"jobs": len(s.jobs),
+	})
+}
+
+// Stop halts the scheduler and waits for running jobs to finish
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+	s.manual.Wait()
+
+	AppLogger.Info("Scheduler stopped", nil)
+}
+
+// StartJob re-enables a stopped job on its last schedule
+func (s *Scheduler) StartJob(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.find(name)
+	if err != nil {
+		return err
+	}
+	if job.enabled {
+		return nil
+	}
+	if job.spec == "" {
+		return fmt.Errorf("job %s has no schedule", name)
+	}
+
+	return s.schedule(job, job.spec)
+}
+
+// StopJob disables a job's schedule. A run in progress is not interrupted.
+func (s *Scheduler) StopJob(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.find(name)
+	if err != nil {
+		return err
+	}
+
+	s.unschedule(job)
+	return nil
+}
+
+// Reschedule replaces a job's cron expression and enables it
+func (s *Scheduler) Reschedule(name string, spec string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.find(name)
+	if err != nil {
+		return err
+	}
+
+	return s.schedule(job, spec)
+}
+
+// RunNow triggers a job immediately in the background
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	job, err := s.find(name)
+	if err == nil && job.running {
+		err = ErrJobRunning
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.manual.Add(1)
+	go func() {
+		defer s.manual.Done()
+		s.execute(job)
+	}()
+	return nil
+}
+
+// Jobs returns the status of every registered job
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		status := JobStatus{
+			Name:       job.name,
+			Schedule:   job.spec,
+			Enabled:    job.enabled,
+			Running:    job.running,
+			DurationMs: job.duration.Milliseconds(),
+			Outcome:    job.outcome,
+			Error:      job.err,
+			Runs:       job.runs,
+		}
+		if !job.lastRun.IsZero() {
+			lastRun := job.lastRun
+			status.LastRun = &lastRun
+		}
+		if job.enabled {
+			next := s.cron.Entry(job.entryID).Next
+			if next.IsZero() {
+				// The scheduler has not started yet, so cron has no next time
+				next = job.schedule.Next(time.Now())
+			}
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// schedule parses spec and (re)registers the job with cron. Callers hold s.mu.
+func (s *Scheduler) schedule(job *scheduledJob, spec string) error {
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	s.unschedule(job)
+	job.spec = spec
+	job.schedule = schedule
+	job.entryID = s.cron.Schedule(schedule, cron.FuncJob(func() { s.execute(job) }))
+	job.enabled = true
+
+	return nil
+}
+
+// unschedule removes the job from cron. Callers hold s.mu.
+func (s *Scheduler) unschedule(job *scheduledJob) {
+	if job.enabled {
+		s.cron.Remove(job.entryID)
+	}
+	job.enabled = false
+	job.entryID = 0
+}
+
+// find looks a job up by name. Callers hold s.mu.
+func (s *Scheduler) find(name string) (*scheduledJob, error) {
+	for _, job := range s.jobs {
+		if job.name == name {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+}
+
+// execute runs a job once, skipping it if the previous run is still going,
+// and records the outcome
+func (s *Scheduler) execute(job *scheduledJob) {
+	s.mu.Lock()
+	if job.running {
+		s.mu.Unlock()
+		AppLogger.Warn("Skipping job run, previous run still in progress", map[string]interface{}{
+			"job": job.name,
+		})
+		return
+	}
+	job.running = true
+	job.lastRun = time.Now()
+	job.outcome = JobOutcomeRunning
+	job.err = ""
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := runJob(job)
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	job.running = false
+	job.duration = duration
+	job.runs++
+	if err != nil {
+		job.outcome = JobOutcomeFailed
+		job.err = err.Error()
+	} else {
+		job.outcome = JobOutcomeSuccess
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		AppLogger.Error("Scheduled job failed", err, map[string]interface{}{
+			"job":         job.name,
+			"duration_ms": duration.Milliseconds(),
+		})
+	} else {
+		AppLogger.Info("Scheduled job completed", map[string]interface{}{
+			"job":         job.name,
+			"duration_ms": duration.Milliseconds(),
+		})
+	}
+}
+
+// runJob calls the job function, turning a panic into an error
+func runJob(job *scheduledJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return job.run()
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+
+	"chklst-go/internal/database"
+)
+
+// Settings import modes
+const (
+	// ImportReplace makes each library category exactly match the file
+	ImportReplace = "replace"
+	// ImportMerge adds the file's entries to the existing ones
+	ImportMerge = "merge"
+)
+
+// ErrInvalidImportMode is returned for an unknown settings import mode
+var ErrInvalidImportMode = fmt.Errorf("import mode must be %q or %q", ImportReplace, ImportMerge)
+
+// LibraryDiff lists the entries an import adds to and removes from one
+// library category
+type LibraryDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// SettingsWarning flags a removed library entry that existing rows still use
+type SettingsWarning struct {
+	Category   string                      `json:"category"`
+	Value      string                      `json:"value"`
+	References []database.LibraryReference `json:"references"`
+}
+
+// SettingsImportReport describes what a settings import changed, or with
+// DryRun what it would change
+type SettingsImportReport struct {
+	Mode     string                 `json:"mode"`
+	DryRun   bool                   `json:"dry_run"`
+	Changed  bool                   `json:"changed"`
+	Changes  map[string]LibraryDiff `json:"changes"`
+	Warnings []SettingsWarning      `json:"warnings"`
+}
+
+// planImport computes the library that results from importing imported into
+// current with the given mode, and reports the differences
+func planImport(current database.Library, imported database.Library, mode string) (database.Library, *SettingsImportReport, error) {
+	if mode != ImportReplace && mode != ImportMerge {
+		return current, nil, ErrInvalidImportMode
+	}
+
+	report := &SettingsImportReport{
+		Mode:     mode,
+		Changes:  make(map[string]LibraryDiff),
+		Warnings: []SettingsWarning{},
+	}
+
+	result := current
+	for _, category := range database.LibraryCategories {
+		existing := current.Values(category)
+		incoming := dedupe(imported.Values(category))
+
+		values := incoming
+		if mode == ImportMerge {
+			values = dedupe(append(append(database.StringArray{}, existing...), incoming...))
+		}
+
+		diff := LibraryDiff{
+			Added:   missingFrom(values, existing),
+			Removed: missingFrom(existing, values),
+		}
+		if len(diff.Added) > 0 || len(diff.Removed) > 0 {
+			report.Changed = true
+		}
+		report.Changes[category] = diff
+
+		for _, value := range diff.Removed {
+			refs, err := database.LibraryReferences(database.DB, category, value)
+			if err != nil {
+				return current, nil, fmt.Errorf("failed to check references: %w", err)
+			}
+			if len(refs) > 0 {
+				report.Warnings = append(report.Warnings, SettingsWarning{
+					Category:   category,
+					Value:      value,
+					References: refs,
+				})
+			}
+		}
+
+		result.SetValues(category, values)
+	}
+
+	return result, report, nil
+}
+
+// dedupe drops repeated and empty entries, keeping the first occurrence
+func dedupe(values database.StringArray) database.StringArray {
+	seen := make(map[string]bool)
+	out := database.StringArray{}
+	for _, value := range values {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		out = append(out, value)
+	}
+	return out
+}
+
+// missingFrom returns the entries of a that are not in b
+func missingFrom(a database.StringArray, b database.StringArray) []string {
+	in := make(map[string]bool)
+	for _, value := range b {
+		in[value] = true
+	}
+
+	out := []string{}
+	for _, value := range a {
+		if !in[value] {
+			out = append(out, value)
+		}
+	}
+	return out
+}
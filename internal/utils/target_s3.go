@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Target stores backups in a bucket on any S3-compatible service
+type S3Target struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	name   string
+}
+
+// NewS3Target creates a target from an s3://[access:secret@]host/bucket/prefix
+// URL. Without credentials in the URL, AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY
+// or MINIO_ACCESS_KEY/MINIO_SECRET_KEY are used.
+func NewS3Target(u *url.URL) (*S3Target, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("s3 backup target needs an endpoint host")
+	}
+
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if parts[0] == "" {
+		return nil, fmt.Errorf("s3 backup target needs a bucket")
+	}
+
+	var creds *credentials.Credentials
+	if u.User != nil {
+		secret, _ := u.User.Password()
+		creds = credentials.NewStaticV4(u.User.Username(), secret, "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+		})
+	}
+
+	query := u.Query()
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: query.Get("insecure") != "true",
+		Region: query.Get("region"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	target := &S3Target{
+		client: client,
+		bucket: parts[0],
+		name:   "s3://" + u.Host + "/" + parts[0],
+	}
+	if len(parts) == 2 {
+		target.prefix = strings.Trim(parts[1], "/")
+		target.name += "/" + target.prefix
+	}
+
+	return target, nil
+}
+
+// Name returns the endpoint, bucket and prefix
+func (t *S3Target) Name() string {
+	return t.name
+}
+
+// key maps a backup name to its object key
+func (t *S3Target) key(name string) string {
+	return path.Join(t.prefix, name)
+}
+
+// Upload puts a backup into the bucket
+func (t *S3Target) Upload(ctx context.Context, name string, localPath string) error {
+	if err := validRemoteName(name); err != nil {
+		return err
+	}
+
+	_, err := t.client.FPutObject(ctx, t.bucket, t.key(name), localPath, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+// Download fetches a backup from the bucket
+func (t *S3Target) Download(ctx context.Context, name string, localPath string) error {
+	if err := validRemoteName(name); err != nil {
+		return err
+	}
+
+	err := t.client.FGetObject(ctx, t.bucket, t.key(name), localPath, minio.GetObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+	return err
+}
+
+// List returns the backups under the target's prefix
+func (t *S3Target) List(ctx context.Context) ([]RemoteBackup, error) {
+	prefix := t.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	backups := []RemoteBackup{}
+	for object := range t.client.ListObjects(ctx, t.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+
+		name := strings.TrimPrefix(object.Key, prefix)
+		if validRemoteName(name) != nil {
+			continue
+		}
+		backups = append(backups, RemoteBackup{Name: name, Size: object.Size, ModifiedAt: object.LastModified})
+	}
+	sortRemoteBackups(backups)
+
+	return backups, nil
+}
+
+// Delete removes a backup from the bucket
+func (t *S3Target) Delete(ctx context.Context, name string) error {
+	if err := validRemoteName(name); err != nil {
+		return err
+	}
+
+	return t.client.RemoveObject(ctx, t.bucket, t.key(name), minio.RemoveObjectOptions{})
+}
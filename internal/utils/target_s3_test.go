@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory S3 endpoint with just enough of the API for
+// S3Target: put, head, get, list (v2) and delete of objects in one bucket.
+// Requests are not authenticated.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{bucket: bucket, objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodPut:
+		body, err := readPayload(r)
+		if err != nil {
+			f.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(body))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// object returns the stored object under key
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// put stores an object under key
+func (f *fakeS3) put(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = data
+}
+
+// list answers a ListObjectsV2 request
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: f.bucket, Prefix: prefix, MaxKeys: 1000}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         etag(f.objects[key]),
+			Size:         len(f.objects[key]),
+		})
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// error writes an S3 error response
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// readPayload reads a request body, decoding aws-chunked uploads, which the
+// client sends over plain HTTP
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var body bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err := io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// etag returns the quoted MD5 S3 uses as a simple object's ETag
+func etag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// newFakeS3Target points an S3Target at a fake endpoint
+func newFakeS3Target(t *testing.T, server *httptest.Server, path string) *S3Target {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("invalid server URL: %v", err)
+	}
+
+	target, err := NewBackupTarget("s3://access:secret@" + u.Host + path + "?region=us-east-1&insecure=true")
+	if err != nil {
+		t.Fatalf("NewBackupTarget: %v", err)
+	}
+	return target.(*S3Target)
+}
+
+func TestS3TargetRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeS3(t, "chklst")
+	target := newFakeS3Target(t, server, "/chklst/nightly")
+
+	if want := "s3://" + strings.TrimPrefix(server.URL, "http://") + "/chklst/nightly"; target.Name() != want {
+		t.Fatalf("Name = %q, want %q", target.Name(), want)
+	}
+
+	name := "chklst_backup_2026-01-02_03-04-05.tar.gz"
+	local := writeBackupFile(t, t.TempDir(), name, "archive contents")
+	if err := target.Upload(ctx, name, local); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if data, _ := fake.object("nightly/" + name); string(data) != "archive contents" {
+		t.Fatalf("bucket holds %q under nightly/%s", data, name)
+	}
+
+	// Objects outside the prefix or that are not backups are not listed
+	fake.put("other/"+name, []byte("x"))
+	fake.put("nightly/readme.txt", []byte("x"))
+
+	backups, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != name || backups[0].Size != int64(len("archive contents")) {
+		t.Fatalf("List = %+v, want %s", backups, name)
+	}
+
+	downloaded := filepath.Join(t.TempDir(), name)
+	if err := target.Download(ctx, name, downloaded); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if data, _ := os.ReadFile(downloaded); string(data) != "archive contents" {
+		t.Fatalf("Download wrote %q", data)
+	}
+
+	if err := target.Delete(ctx, name); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := fake.object("nightly/" + name); ok {
+		t.Fatalf("Delete left nightly/%s in the bucket", name)
+	}
+	if err := target.Download(ctx, name, downloaded); !errors.Is(err, ErrBackupNotFound) {
+		t.Fatalf("Download after Delete = %v, want ErrBackupNotFound", err)
+	}
+}
+
+func TestNewS3TargetRequiresBucket(t *testing.T) {
+	for _, raw := range []string{"s3:///chklst", "s3://minio:9000", "s3://minio:9000/"} {
+		if _, err := NewBackupTarget(raw); err == nil {
+			t.Errorf("NewBackupTarget(%q) succeeded, want an error", raw)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	AppLogger = NewLogger(ERROR)
+	os.Exit(m.Run())
+}
+
+// writeBackupFile writes a fake backup named name into dir
+func writeBackupFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLocalTargetRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	target, err := NewLocalTarget(filepath.Join(t.TempDir(), "remote"))
+	if err != nil {
+		t.Fatalf("NewLocalTarget: %v", err)
+	}
+
+	name := "chklst_backup_2026-01-02_03-04-05.tar.gz"
+	local := writeBackupFile(t, t.TempDir(), name, "archive contents")
+	if err := target.Upload(ctx, name, local); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	backups, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != name || backups[0].Size != int64(len("archive contents")) {
+		t.Fatalf("List = %+v, want %s", backups, name)
+	}
+
+	downloaded := filepath.Join(t.TempDir(), name)
+	if err := target.Download(ctx, name, downloaded); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if data, _ := os.ReadFile(downloaded); !bytes.Equal(data, []byte("archive contents")) {
+		t.Fatalf("Download wrote %q", data)
+	}
+
+	if err := target.Delete(ctx, name); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if backups, _ := target.List(ctx); len(backups) != 0 {
+		t.Fatalf("List after Delete = %+v, want none", backups)
+	}
+	if err := target.Download(ctx, name, downloaded); !errors.Is(err, ErrBackupNotFound) {
+		t.Fatalf("Download after Delete = %v, want ErrBackupNotFound", err)
+	}
+}
+
+func TestLocalTargetRejectsUnsafeNames(t *testing.T) {
+	ctx := context.Background()
+	target, err := NewLocalTarget(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalTarget: %v", err)
+	}
+	local := writeBackupFile(t, t.TempDir(), "chklst_backup.tar.gz", "x")
+
+	for _, name := range []string{"", "../chklst_backup.tar.gz", ".hidden.tar.gz", "notes.txt"} {
+		if err := target.Upload(ctx, name, local); !errors.Is(err, ErrBackupNotFound) {
+			t.Errorf("Upload(%q) = %v, want ErrBackupNotFound", name, err)
+		}
+	}
+}
+
+func TestPruneDeletesFromTargets(t *testing.T) {
+	ctx := context.Background()
+	target, err := NewLocalTarget(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalTarget: %v", err)
+	}
+
+	backupDir := t.TempDir()
+	bm := NewBackupManager(backupDir, BackupOptions{
+		Retention: RetentionPolicy{Daily: 1},
+		Targets:   []BackupTarget{target},
+	})
+
+	now := time.Now()
+	older := "chklst_backup_" + now.Add(-48*time.Hour).Format("2006-01-02_15-04-05") + archiveExt
+	newer := "chklst_backup_" + now.Format("2006-01-02_15-04-05") + archiveExt
+	for _, name := range []string{older, newer} {
+		if err := target.Upload(ctx, name, writeBackupFile(t, backupDir, name, name)); err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+	}
+	// Only held remotely, so pruning must leave it alone
+	remoteOnly := "chklst_backup_2020-01-01_00-00-00" + archiveExt
+	if err := target.Upload(ctx, remoteOnly, filepath.Join(backupDir, older)); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	result, err := bm.Prune(false)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(result.Pruned) != 1 || result.Pruned[0].Name != older {
+		t.Fatalf("Pruned = %+v, want %s", result.Pruned, older)
+	}
+
+	backups, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	if len(names) != 2 || !containsName(names, newer) || !containsName(names, remoteOnly) {
+		t.Fatalf("target holds %v, want %s and %s", names, newer, remoteOnly)
+	}
+
+	// A second run finds nothing left to remove on either side
+	if result, err := bm.Prune(false); err != nil || len(result.Pruned) != 0 {
+		t.Fatalf("second Prune = %+v, %v", result, err)
+	}
+}
+
+// containsName reports whether names holds name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
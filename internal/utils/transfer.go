@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// uploadPrefix marks uploaded files staged in the backup directory. Hidden
+// files are skipped by ListBackups and pruning.
+const uploadPrefix = ".upload-"
+
+// ResolveBackup maps a backup reference from an API request to a file in the
+// backup directory. The reference is a backup name, or for older clients the
+// full path returned when the backup was created. Anything outside the backup
+// directory is rejected.
+func (bm *BackupManager) ResolveBackup(ref string) (string, error) {
+	name := filepath.Base(ref)
+	if name != ref {
+		dir, err := filepath.Abs(filepath.Dir(ref))
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrBackupNotFound, ref)
+		}
+		backupDir, err := filepath.Abs(bm.backupDir)
+		if err != nil || dir != backupDir {
+			return "", fmt.Errorf("%w: %s", ErrBackupNotFound, ref)
+		}
+	}
+
+	return bm.BackupPath(name)
+}
+
+// StageUpload writes an uploaded file into the backup directory under a
+// hidden temporary name that keeps the original extension, so the backup kind
+// is still recognised. filename must be one of the allowed kinds. The caller
+// removes the staged file when done.
+func (bm *BackupManager) StageUpload(filename string, src io.Reader, allowed ...string) (string, error) {
+	name := filepath.Base(filename)
+	kind := backupKind(name)
+
+	ok := false
+	for _, k := range allowed {
+		if kind == k {
+			ok = true
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported file type: %s", ErrInvalidBackup, name)
+	}
+
+	dst, err := os.CreateTemp(bm.backupDir, uploadPrefix+"*-"+name)
+	if err != nil {
+		return "", fmt.Errorf("failed to stage upload: %w", err)
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to stage upload: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to stage upload: %w", err)
+	}
+
+	AppLogger.Info("Upload staged", map[string]interface{}{
+		"file": name,
+		"path": dst.Name(),
+	})
+
+	return dst.Name(), nil
+}
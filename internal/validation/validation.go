@@ -0,0 +1,235 @@
+package validation
+
+import (
+	"chklst-go/internal/database"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Request and model structs declare their rules in a `validate` tag. Rules
+// are comma separated and checked in order; a field reports its first
+// failure only.
+//
+//	required               the value must be set
+//	jira                   a set value must be a Jira issue key such as PAT-123
+//	exists=<table>         a set ID must name a row of table
+//	belongs=<column>:<Field>  the row found by exists must have column equal to Field
+//	library=<category>     a set name must be listed in the library category
+//
+// Errors name fields by their JSON name.
+
+// jiraKey matches Jira issue keys: a project key, a dash and a number
+var jiraKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-[0-9]+$`)
+
+// FieldError describes a field that failed a rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of field errors of a struct
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// rule is one parsed entry of a validate tag
+type rule struct {
+	name  string
+	param string
+}
+
+// Validate checks v, a pointer to a struct, against its validate tags. When
+// fields are given only those JSON fields, and fields whose belongs rule
+// refers to them, are checked. The error is only set when the database
+// could not be queried.
+func Validate(db *gorm.DB, v interface{}, fields ...string) (Errors, error) {
+	value := reflect.ValueOf(v).Elem()
+	t := value.Type()
+
+	var errs Errors
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		rules := parseRules(tag)
+		name := jsonName(field)
+		if len(fields) > 0 && !selected(t, name, rules, fields) {
+			continue
+		}
+
+		// Belonging is not checked against an owner that already failed
+		owner := belongsTo(t, rules)
+		ownerFailed := owner != "" && failed(errs, owner)
+
+		fe, err := check(db, value, value.Field(i), name, rules, ownerFailed)
+		if err != nil {
+			return nil, err
+		}
+		if fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	return errs, nil
+}
+
+// check applies rules to a field and returns its first failure
+func check(db *gorm.DB, parent reflect.Value, field reflect.Value, name string, rules []rule, ownerFailed bool) (*FieldError, error) {
+	value, set := indirect(field)
+	table := ""
+
+	for _, r := range rules {
+		if r.name == "required" {
+			if !set {
+				return &FieldError{Field: name, Rule: r.name, Message: "is required"}, nil
+			}
+			continue
+		}
+		if !set {
+			return nil, nil
+		}
+
+		switch r.name {
+		case "jira":
+			if !jiraKey.MatchString(value.String()) {
+				return &FieldError{Field: name, Rule: r.name, Message: "must be a Jira issue key such as PAT-123"}, nil
+			}
+
+		case "exists":
+			table = r.param
+			var count int64
+			if err := db.Table(table).Where("id = ?", value.Interface()).Count(&count).Error; err != nil {
+				return nil, fmt.Errorf("failed to check %s: %w", name, err)
+			}
+			if count == 0 {
+				return &FieldError{Field: name, Rule: r.name, Message: fmt.Sprintf("%s %v does not exist", singular(table), value.Interface())}, nil
+			}
+
+		case "belongs":
+			column, other, _ := strings.Cut(r.param, ":")
+			owner, ok := indirect(parent.FieldByName(other))
+			if !ok || table == "" || ownerFailed {
+				continue
+			}
+			var count int64
+			err := db.Table(table).Where("id = ? AND "+column+" = ?", value.Interface(), owner.Interface()).Count(&count).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s: %w", name, err)
+			}
+			if count == 0 {
+				return &FieldError{Field: name, Rule: r.name, Message: fmt.Sprintf("%s %v does not belong to %s %v", singular(table), value.Interface(), strings.TrimSuffix(column, "_id"), owner.Interface())}, nil
+			}
+
+		case "library":
+			found, err := database.InLibrary(db, r.param, value.String())
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s: %w", name, err)
+			}
+			if !found {
+				return &FieldError{Field: name, Rule: r.name, Message: fmt.Sprintf("%q is not in the library's %s", value.String(), strings.ReplaceAll(r.param, "_", " "))}, nil
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q on %s", r.name, name)
+		}
+	}
+	return nil, nil
+}
+
+// parseRules splits a validate tag into its rules
+func parseRules(tag string) []rule {
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		rules = append(rules, rule{name: name, param: param})
+	}
+	return rules
+}
+
+// selected reports whether a field is among the JSON fields to check, or
+// belongs to a field that is
+func selected(t reflect.Type, name string, rules []rule, fields []string) bool {
+	if contains(fields, name) {
+		return true
+	}
+	owner := belongsTo(t, rules)
+	return owner != "" && contains(fields, owner)
+}
+
+// belongsTo returns the JSON name of the field a belongs rule refers to
+func belongsTo(t reflect.Type, rules []rule) string {
+	for _, r := range rules {
+		if r.name != "belongs" {
+			continue
+		}
+		_, other, _ := strings.Cut(r.param, ":")
+		if field, ok := t.FieldByName(other); ok {
+			return jsonName(field)
+		}
+	}
+	return ""
+}
+
+// failed reports whether errs has an error for field
+func failed(errs Errors, field string) bool {
+	for _, fe := range errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// indirect dereferences pointers and reports whether the value is set.
+// Blank strings, zero numbers and nil pointers are not set.
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return v, false
+	}
+	if v.Kind() == reflect.String {
+		return v, strings.TrimSpace(v.String()) != ""
+	}
+	return v, !v.IsZero()
+}
+
+// jsonName returns the JSON name of a struct field
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// singular turns a table name into the name of one of its rows
+func singular(table string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(table, "s"), "_", " ")
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
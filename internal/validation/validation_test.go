@@ -0,0 +1,203 @@
+package validation
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"chklst-go/internal/database"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB points database.DB at a migrated database in a temporary
+// directory holding projects 1 and 2, component 1 of project 1, the
+// staging environment and a build server named ci
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDatabase(filepath.Join(t.TempDir(), "chklst.db")); err != nil {
+		t.Fatalf("InitDatabase: %v", err)
+	}
+	database.DB.Logger = logger.Discard
+	t.Cleanup(func() { database.CloseDatabase() })
+
+	if _, err := database.MigrateUp(0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	for _, row := range []interface{}{
+		&database.Project{Name: "billing", Version: 1},
+		&database.Project{Name: "payroll", Version: 1},
+		&database.Component{ProjectID: 1, Name: "api", Version: 1},
+		&database.Server{Name: "ci", Build: true, Version: 1},
+		&database.Server{Name: "web01", Deploy: true, Version: 1},
+	} {
+		if err := database.DB.Create(row).Error; err != nil {
+			t.Fatalf("failed to create %T: %v", row, err)
+		}
+	}
+	if err := database.AddLibraryEntry(database.DB, database.LibraryEnvironments, "staging"); err != nil {
+		t.Fatalf("AddLibraryEntry: %v", err)
+	}
+}
+
+// release has one field for each rule
+type release struct {
+	Title       string  `json:"title" validate:"required"`
+	JiraID      string  `json:"jira_id" validate:"jira"`
+	ProjectID   uint    `json:"project_id" validate:"required,exists=projects"`
+	ComponentID *uint   `json:"component_id" validate:"exists=components,belongs=project_id:ProjectID"`
+	Environment string  `json:"environment" validate:"library=environments"`
+	BuildServer string  `json:"build_server" validate:"library=build_servers"`
+	Notes       string  `json:"notes"`
+	Reviewer    *string `json:"reviewer,omitempty" validate:"required"`
+}
+
+func uintPtr(v uint) *uint { return &v }
+
+func stringPtr(v string) *string { return &v }
+
+// valid returns a release that passes every rule
+func valid() release {
+	return release{
+		Title:       "Quarterly release",
+		JiraID:      "PAT-123",
+		ProjectID:   1,
+		ComponentID: uintPtr(1),
+		Environment: "staging",
+		BuildServer: "ci",
+		Reviewer:    stringPtr("ana"),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	openTestDB(t)
+
+	tests := []struct {
+		name   string
+		change func(*release)
+		want   Errors
+	}{
+		{"valid", func(r *release) {}, nil},
+		{"optional fields may be blank", func(r *release) {
+			r.JiraID, r.ComponentID, r.Environment, r.BuildServer = "", nil, "", ""
+		}, nil},
+		{"required string", func(r *release) { r.Title = "  " }, Errors{
+			{Field: "title", Rule: "required", Message: "is required"},
+		}},
+		{"required pointer", func(r *release) { r.Reviewer = nil }, Errors{
+			{Field: "reviewer", Rule: "required", Message: "is required"},
+		}},
+		{"required pointer to a blank string", func(r *release) { r.Reviewer = stringPtr("") }, Errors{
+			{Field: "reviewer", Rule: "required", Message: "is required"},
+		}},
+		{"jira key", func(r *release) { r.JiraID = "pat-123" }, Errors{
+			{Field: "jira_id", Rule: "jira", Message: "must be a Jira issue key such as PAT-123"},
+		}},
+		{"missing project reports required, not exists", func(r *release) { r.ProjectID = 0; r.ComponentID = nil }, Errors{
+			{Field: "project_id", Rule: "required", Message: "is required"},
+		}},
+		{"unknown project", func(r *release) { r.ProjectID = 9; r.ComponentID = nil }, Errors{
+			{Field: "project_id", Rule: "exists", Message: "project 9 does not exist"},
+		}},
+		{"unknown component", func(r *release) { r.ComponentID = uintPtr(9) }, Errors{
+			{Field: "component_id", Rule: "exists", Message: "component 9 does not exist"},
+		}},
+		{"component of another project", func(r *release) { r.ProjectID = 2 }, Errors{
+			{Field: "component_id", Rule: "belongs", Message: "component 1 does not belong to project 2"},
+		}},
+		{"belongs is skipped when the owner failed", func(r *release) { r.ProjectID = 9 }, Errors{
+			{Field: "project_id", Rule: "exists", Message: "project 9 does not exist"},
+		}},
+		{"not in the library", func(r *release) { r.Environment = "qa" }, Errors{
+			{Field: "environment", Rule: "library", Message: `"qa" is not in the library's environments`},
+		}},
+		{"server without the role", func(r *release) { r.BuildServer = "web01" }, Errors{
+			{Field: "build_server", Rule: "library", Message: `"web01" is not in the library's build servers`},
+		}},
+		{"errors are listed in field order", func(r *release) { r.Title = ""; r.JiraID = "x"; r.Environment = "qa" }, Errors{
+			{Field: "title", Rule: "required", Message: "is required"},
+			{Field: "jira_id", Rule: "jira", Message: "must be a Jira issue key such as PAT-123"},
+			{Field: "environment", Rule: "library", Message: `"qa" is not in the library's environments`},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.change(&r)
+
+			errs, err := Validate(database.DB, &r)
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Fatalf("errors = %v, want %v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSelectedFields(t *testing.T) {
+	openTestDB(t)
+
+	// Every field fails, so the errors show which fields were checked
+	r := release{JiraID: "x", ProjectID: 2, ComponentID: uintPtr(1), Environment: "qa"}
+
+	tests := []struct {
+		fields []string
+		want   []string
+	}{
+		{nil, []string{"title", "jira_id", "component_id", "environment", "reviewer"}},
+		{[]string{"jira_id"}, []string{"jira_id"}},
+		{[]string{"notes"}, nil},
+		// A new owner checks the fields that belong to it
+		{[]string{"project_id"}, []string{"component_id"}},
+		{[]string{"component_id"}, []string{"component_id"}},
+		{[]string{"environment", "title"}, []string{"title", "environment"}},
+	}
+
+	for _, tt := range tests {
+		errs, err := Validate(database.DB, &r, tt.fields...)
+		if err != nil {
+			t.Fatalf("Validate(%v): %v", tt.fields, err)
+		}
+		var got []string
+		for _, fe := range errs {
+			got = append(got, fe.Field)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Validate(%v) failed %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	openTestDB(t)
+
+	var v struct {
+		Name string `json:"name" validate:"required,email"`
+	}
+	v.Name = "ana"
+	if _, err := Validate(database.DB, &v); err == nil {
+		t.Fatal("Validate accepted an unknown rule")
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+	errs := Errors{
+		{Field: "title", Rule: "required", Message: "is required"},
+		{Field: "jira_id", Rule: "jira", Message: "must be a Jira issue key such as PAT-123"},
+	}
+	if got, want := errs.Error(), "title is required; jira_id must be a Jira issue key such as PAT-123"; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+}